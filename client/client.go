@@ -9,6 +9,12 @@ import (
 )
 
 func main() {
+	// "client replay ..." opens the replay viewer instead of playing
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplayViewer(os.Args[2:])
+		return
+	}
+
 	fmt.Print("Enter username: ")
 	username := readLine()
 	fmt.Print("Enter password: ")
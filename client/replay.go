@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
+)
+
+// frameTicks is how far the viewer moves per frame: one second of game time
+const frameTicks = engine.TickRate
+
+// runReplayViewer plays back a replay file, or a finished game downloaded
+// from the server, frame by frame in the terminal
+func runReplayViewer(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	match := fs.Int("match", 0, "download this match from the server instead of opening a file")
+	addr := fs.String("server", "localhost:8080", "server to download the match from")
+	viewer := fs.Int("as", 1, "show the map from this player's side (1 or 2)")
+	export := fs.String("export", "", "write the match to standard output as text (transcript) or cast (asciicast v2) instead of watching")
+	fs.Parse(args)
+	if (*match == 0) == (fs.NArg() == 0) || (*viewer != 1 && *viewer != 2) ||
+		(*export != "" && *export != "text" && *export != "cast") {
+		fmt.Println("Usage: client replay [-as 1|2] [-export text|cast] <replay file>")
+		fmt.Println("       client replay [-as 1|2] [-export text|cast] [-server host:port] -match <id>")
+		return
+	}
+
+	var data []byte
+	var err error
+	if *match != 0 {
+		data, err = downloadReplay(*addr, *match)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		fmt.Println("Cannot get replay:", err)
+		return
+	}
+	r, err := engine.ParseReplay(data)
+	if err != nil {
+		fmt.Println("Cannot load replay:", err)
+		return
+	}
+	switch *export {
+	case "text":
+		err = engine.WriteTranscript(os.Stdout, r)
+	case "cast":
+		err = engine.WriteAsciicast(os.Stdout, r, *viewer)
+	default:
+		watchReplay(r, *viewer)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Export failed:", err)
+	}
+}
+
+// downloadReplay logs in and asks the server for a finished game's replay
+func downloadReplay(addr string, match int) ([]byte, error) {
+	// Prompts go to stderr so an export written to stdout stays clean
+	fmt.Fprint(os.Stderr, "Enter username: ")
+	username := readLine()
+	fmt.Fprint(os.Stderr, "Enter password: ")
+	password := readLine()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// Logging in for a download skips the menu and any interrupted game
+	fmt.Fprintf(conn, "%s:%s:replay\n%d\n", username, password, match)
+
+	// Everything before the replay is the login and match prompts; the last
+	// of them explains why, if the server hangs up without sending one
+	serverReader := bufio.NewReader(conn)
+	last := "server closed the connection"
+	for {
+		line, err := serverReader.ReadString('\n')
+		if strings.HasPrefix(line, "REPLAY ") {
+			return []byte(strings.TrimPrefix(line, "REPLAY ")), nil
+		}
+		if text := strings.TrimSpace(line); text != "" {
+			last = text
+		}
+		if err != nil {
+			return nil, errors.New(last)
+		}
+	}
+}
+
+// watchReplay shows a playback one frame per second of game time, divided by
+// the speed, and follows the controls typed on stdin
+func watchReplay(r *engine.Replay, viewer int) {
+	p := r.NewPlayback()
+	speed := 1
+	paused := false
+	var log []string // Events since the frame last shown
+
+	input := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			input <- strings.TrimSpace(scanner.Text())
+		}
+		close(input)
+	}()
+
+	// advance plays up to n ticks, collecting what happened
+	advance := func(n int) {
+		for i := 0; i < n && !p.Done(); i++ {
+			for _, ev := range p.Step() {
+				if _, ok := ev.(engine.CommandRejected); ok {
+					continue
+				}
+				if text := p.State.Describe(ev); text != "" {
+					log = append(log, fmt.Sprintf("[%s] %s", p.State.Elapsed(), text))
+				}
+			}
+		}
+	}
+	// seek jumps to a tick; events skipped over are not shown
+	seek := func(tick int) {
+		p.Seek(max(tick, 0))
+		log = nil
+	}
+	show := func() {
+		s := p.State
+		state := ""
+		if paused {
+			state = " [paused]"
+		}
+		fmt.Printf("\nReplay: %s (P1) vs %s (P2), Tick %d/%d (%s), Crowns: %d-%d, Speed x%d%s\n",
+			r.Players[0], r.Players[1], s.Tick, r.Ticks, s.Elapsed(),
+			engine.Crowns(s, 1), engine.Crowns(s, 2), speed, state)
+		for _, line := range log {
+			fmt.Println(line)
+		}
+		log = nil
+		fmt.Println(engine.RenderMap(s, viewer))
+		if p.Done() {
+			winner, reason := r.Result(s)
+			if winner == 0 {
+				fmt.Printf("End of replay: draw (%s)\n", engine.ReasonText[reason])
+			} else {
+				fmt.Printf("End of replay: %s wins (%s)\n", r.Players[winner-1], engine.ReasonText[reason])
+			}
+		}
+	}
+
+	fmt.Println("Controls: p pause/resume, n [count] step forward, b [count] step back,")
+	fmt.Println("          + faster, - slower, j <tick or 1m30s> jump, q quit")
+	show()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if paused || p.Done() {
+				continue
+			}
+			advance(frameTicks)
+			show()
+
+		case line, ok := <-input:
+			if !ok {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			count := 1
+			if len(fields) > 1 {
+				if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+					count = n
+				}
+			}
+			switch fields[0] {
+			case "q":
+				return
+			case "p":
+				paused = !paused
+			case "n":
+				paused = true
+				advance(count * frameTicks)
+			case "b":
+				paused = true
+				seek(p.State.Tick - count*frameTicks)
+			case "+":
+				speed = min(speed*2, 16)
+				ticker.Reset(time.Second / time.Duration(speed))
+			case "-":
+				speed = max(speed/2, 1)
+				ticker.Reset(time.Second / time.Duration(speed))
+			case "j":
+				tick, err := parseTick(fields[1:])
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				paused = true
+				seek(tick)
+			default:
+				fmt.Println("Unknown control:", fields[0])
+				continue
+			}
+			show()
+		}
+	}
+}
+
+// parseTick reads a jump target given as a tick number or a game time such as 1m30s
+func parseTick(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errors.New("Usage: j <tick or game time, e.g. 600 or 1m30s>")
+	}
+	if tick, err := strconv.Atoi(args[0]); err == nil {
+		return tick, nil
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("Cannot jump to %q: give a tick or a game time such as 1m30s", args[0])
+	}
+	return int(d / engine.TickDuration), nil
+}
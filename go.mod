@@ -0,0 +1,3 @@
+module github.com/dinh21176/Netcentric_TCR
+
+go 1.22
@@ -0,0 +1,52 @@
+// Package bots holds the computer opponents. A bot is a Strategy that looks
+// at a read-only View of the match and answers with commands, written the
+// same way a player types them.
+package bots
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+)
+
+// Strategy decides what a bot plays
+type Strategy interface {
+	// Delay is how long the bot waits before each decision
+	Delay() time.Duration
+	// Decide returns the commands to play now, e.g. "K-L" or "F-R-3".
+	// Commands the rules reject are ignored.
+	Decide(v *View) []string
+}
+
+// Factory creates the strategy for one game. rng should be the strategy's
+// only source of randomness.
+type Factory func(rng *rand.Rand) Strategy
+
+// strategies maps strategy names to their factories
+var strategies = map[string]Factory{}
+
+// Register makes a strategy available by name. It is meant to be called
+// from init functions.
+func Register(name string, f Factory) {
+	strategies[name] = f
+}
+
+// New creates a registered strategy for one game
+func New(name string, rng *rand.Rand) (Strategy, error) {
+	f := strategies[name]
+	if f == nil {
+		return nil, fmt.Errorf("unknown bot strategy %q", name)
+	}
+	return f(rng), nil
+}
+
+// Names lists the registered strategies in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
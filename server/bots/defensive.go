@@ -0,0 +1,288 @@
+package bots
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
+)
+
+// Tuning of the defensive bot
+const (
+	counterMana = 7   // Mana saved up before backing a counterattack
+	lowTower    = 0.5 // Share of its HP below which a tower is worth healing
+)
+
+func init() {
+	Register("defensive", func(*rand.Rand) Strategy { return defensive{} })
+}
+
+// defensive reads the board every second. It meets the enemy troop closest
+// to its towers with the card that wins that matchup, then keeps its mana
+// until a stopped push can be turned into a counterattack. A Queen is sent
+// out whenever one of its towers runs low.
+type defensive struct{}
+
+func (defensive) Delay() time.Duration { return time.Second }
+
+func (defensive) Decide(v *View) []string {
+	if threat, ok := closestThreat(v); ok && !held(v, threat) {
+		// Mana goes to the defence first, even if that means waiting for it
+		if command := defend(v, threat); command != "" {
+			return []string{command}
+		}
+		return nil
+	}
+	if command := healTower(v); command != "" {
+		return []string{command}
+	}
+	if command := counterattack(v); command != "" {
+		return []string{command}
+	}
+	return nil
+}
+
+// fighter is what decides a duel between two troops
+type fighter struct {
+	hp, atk, def int
+	cooldown     float64 // Seconds between attacks
+}
+
+// cardFighter is the troop a card would deploy for the bot
+func cardFighter(v *View, def *engine.TroopDef) fighter {
+	level := v.Level()
+	return fighter{
+		hp:       engine.ScaleStat(def.HP, level),
+		atk:      engine.ScaleStat(def.Atk, level),
+		def:      engine.ScaleStat(def.Def, level),
+		cooldown: def.AttackCooldown,
+	}
+}
+
+// troopFighter is a troop already on the map, shield included
+func troopFighter(v *View, t engine.Troop) fighter {
+	f := fighter{hp: t.HP + t.Shield, atk: t.Atk, def: t.Def, cooldown: 1}
+	if def := v.Catalog().Troop(t.Type); def != nil {
+		f.cooldown = def.AttackCooldown
+	}
+	return f
+}
+
+// timeToKill estimates how many seconds a needs to bring b down, ignoring
+// abilities and critical hits. It is +Inf if a cannot hurt b.
+func timeToKill(a, b fighter) float64 {
+	damage := a.atk - b.def
+	if damage <= 0 {
+		return math.Inf(1)
+	}
+	hits := (b.hp + damage - 1) / damage
+	return float64(hits) * a.cooldown
+}
+
+// closestThreat returns the enemy troop nearest the bot's towers, if one has
+// come past the middle of its lane
+func closestThreat(v *View) (engine.Troop, bool) {
+	var threat engine.Troop
+	found := false
+	for _, t := range v.Troops() {
+		if t.Player != v.Opponent() || v.Cell(t) > v.DeployLimit(t.Lane)+1 {
+			continue
+		}
+		if !found || v.Cell(t) < v.Cell(threat) {
+			threat, found = t, true
+		}
+	}
+	return threat, found
+}
+
+// held reports whether one of the bot's troops already stands between the
+// threat and the towers and wins the duel against it
+func held(v *View, threat engine.Troop) bool {
+	foe := troopFighter(v, threat)
+	for _, t := range v.Troops() {
+		if t.Player != v.Player() || t.Lane != threat.Lane || v.Cell(t) > v.Cell(threat) {
+			continue
+		}
+		ours := troopFighter(v, t)
+		if timeToKill(ours, foe) < timeToKill(foe, ours) {
+			return true
+		}
+	}
+	return false
+}
+
+// counter picks the card in hand that deals best with an enemy troop: the
+// cheapest one that wins the duel or kills it outright, otherwise the troop
+// that comes closest to winning. ok is false if nothing in hand can hurt it.
+func counter(v *View, enemy engine.Troop) (card string, ok bool) {
+	type option struct {
+		card   string
+		wins   bool
+		margin float64 // Time the enemy needs to kill ours over the time ours needs
+		cost   int
+	}
+	better := func(a, b option) bool {
+		if a.wins != b.wins {
+			return a.wins
+		}
+		if a.wins && a.cost != b.cost {
+			return a.cost < b.cost
+		}
+		return a.margin > b.margin
+	}
+
+	foe := troopFighter(v, enemy)
+	var best option
+	for _, card := range v.Hand() {
+		o := option{card: card, cost: v.Catalog().CardMana(card)}
+		if def := v.Catalog().Troop(card); def != nil {
+			ours := cardFighter(v, def)
+			kill := timeToKill(ours, foe)
+			if math.IsInf(kill, 1) {
+				continue
+			}
+			o.margin = timeToKill(foe, ours) / kill
+			o.wins = o.margin > 1
+		} else if sp := v.Catalog().Spell(card); sp != nil && sp.Damage >= foe.hp {
+			// Spell damage ignores defense
+			o.wins, o.margin = true, math.Inf(1)
+		} else {
+			continue
+		}
+		if !ok || better(o, best) {
+			best, ok = o, true
+		}
+	}
+	return best.card, ok
+}
+
+// defend returns the command that answers a threat, or "" while the bot
+// cannot pay for its best answer yet
+func defend(v *View, threat engine.Troop) string {
+	card, ok := counter(v, threat)
+	if !ok || float64(v.Catalog().CardMana(card)) > v.Mana() {
+		return ""
+	}
+	cell := v.Cell(threat)
+	if sp := v.Catalog().Spell(card); sp != nil {
+		if sp.TargetsCell() {
+			return fmt.Sprintf("%s-%s-%d", card, threat.Lane, cell)
+		}
+		return fmt.Sprintf("%s-%s", card, threat.Lane)
+	}
+	// Melee troops meet the threat head on; ranged ones stay back and shoot
+	cell = max(1, min(cell-v.Catalog().Troop(card).Range, v.DeployLimit(threat.Lane)))
+	return fmt.Sprintf("%s-%s-%d", card, threat.Lane, cell)
+}
+
+// healTower sends a Queen down the emptiest lane when one of the bot's towers
+// is low. She heals the weakest tower once she comes within range of the
+// enemy towers, so of equally empty lanes the one where she walks the least
+// is picked.
+func healTower(v *View) string {
+	queen := ""
+	for _, card := range v.Hand() {
+		if def := v.Catalog().Troop(card); def != nil && def.HasAbility("tower_heal") {
+			queen = card
+		}
+	}
+	if queen == "" || float64(v.Catalog().CardMana(queen)) > v.Mana() {
+		return ""
+	}
+
+	low := false
+	for _, lane := range v.Layout().LaneIDs() {
+		if t, ok := v.Tower(v.Player(), lane); ok && t.HP > 0 && float64(t.HP) < lowTower*float64(t.MaxHP) {
+			low = true
+		}
+	}
+	if !low {
+		return ""
+	}
+
+	enemies := map[string]int{}
+	for _, t := range v.Troops() {
+		if t.Player == v.Opponent() {
+			enemies[t.Lane]++
+		}
+	}
+	lanes := v.Layout().LaneIDs()
+	lane := lanes[0]
+	for _, id := range lanes {
+		if enemies[id] < enemies[lane] || enemies[id] == enemies[lane] && healWalk(v, id) < healWalk(v, lane) {
+			lane = id
+		}
+	}
+	return fmt.Sprintf("%s-%s-%d", queen, lane, v.DeployLimit(lane))
+}
+
+// healWalk returns how many cells a troop deployed as far forward as allowed
+// in a lane walks before it comes within range of the enemy towers
+func healWalk(v *View, lane string) int {
+	length := v.Layout().LaneLength
+	reach := length // Next to the enemy towers
+	if t, ok := v.Tower(v.Opponent(), lane); ok && t.HP > 0 {
+		reach = max(1, length+1-v.Catalog().Tower(t.Kind).Range)
+	}
+	return max(0, reach-v.DeployLimit(lane))
+}
+
+// counterattack backs the bot's surviving defenders once a push has been
+// stopped, with the strongest troop it can afford after saving counterMana.
+// With no push to back it only attacks to avoid wasting mana at the cap,
+// on the lane whose enemy tower is weakest.
+func counterattack(v *View) string {
+	strength := map[string]int{}
+	contested := map[string]bool{}
+	for _, t := range v.Troops() {
+		if t.Player == v.Player() {
+			strength[t.Lane] += t.HP
+		} else {
+			contested[t.Lane] = true
+		}
+	}
+	lane := ""
+	for _, id := range v.Layout().LaneIDs() {
+		if strength[id] > 0 && !contested[id] && (lane == "" || strength[id] > strength[lane]) {
+			lane = id
+		}
+	}
+
+	switch {
+	case lane != "" && v.Mana() >= counterMana:
+	case v.Mana() >= v.ManaCap()-0.5:
+		lane = weakestEnemyLane(v)
+	default:
+		return ""
+	}
+
+	card := ""
+	var best fighter
+	for _, c := range v.Hand() {
+		def := v.Catalog().Troop(c)
+		if def == nil || def.HasAbility("tower_heal") || float64(def.Mana) > v.Mana() {
+			continue
+		}
+		if f := cardFighter(v, def); card == "" || f.hp+f.atk > best.hp+best.atk {
+			card, best = c, f
+		}
+	}
+	if card == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s-%d", card, lane, v.DeployLimit(lane))
+}
+
+// weakestEnemyLane is the lane whose enemy guard tower has the least HP
+// left, or the King's lane once every guard tower has fallen
+func weakestEnemyLane(v *View) string {
+	lane, lowest := v.Layout().KingLane(), math.MaxInt
+	for _, id := range v.Layout().LaneIDs() {
+		if t, ok := v.Tower(v.Opponent(), id); ok && t.HP > 0 && t.Kind != "King" && t.HP < lowest {
+			lane, lowest = id, t.HP
+		}
+	}
+	return lane
+}
@@ -0,0 +1,179 @@
+package bots
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
+)
+
+// Tuning of the insane bot
+const (
+	insaneBudget  = 200 * time.Millisecond // Thinking time per decision
+	insaneHorizon = 15 * time.Second       // Match time each rollout plays ahead
+	playoutEvery  = time.Second            // How often rollout players consider playing a card
+)
+
+func init() {
+	Register("insane", func(rng *rand.Rand) Strategy { return insane{rng: rng} })
+}
+
+// insane looks ahead. For every move it could make now, and for holding on
+// to its mana, it plays the match forward insaneHorizon on a copy of the
+// state with both sides playing random cards. It does this round after round
+// until insaneBudget is spent, then picks the move whose rollouts ended with
+// the best tower HP difference on average.
+type insane struct {
+	rng *rand.Rand
+}
+
+func (insane) Delay() time.Duration { return time.Second }
+
+func (b insane) Decide(v *View) []string {
+	moves := append([]string{""}, candidateMoves(v)...) // "" holds on to the mana
+	if len(moves) == 1 {
+		return nil
+	}
+
+	// Every move is tried against the same random futures in a round, so the
+	// totals differ because of the moves rather than luck. A round cut short
+	// by the deadline is dropped, unless no round was finished at all.
+	totals := make([]float64, len(moves))
+	deadline := time.Now().Add(insaneBudget)
+	for rounds := 0; ; rounds++ {
+		seed := b.rng.Uint64()
+		round := make([]float64, 0, len(moves))
+		for _, move := range moves {
+			if !time.Now().Before(deadline) {
+				break
+			}
+			round = append(round, rollout(v, move, seed))
+		}
+		if len(round) < len(moves) {
+			if rounds == 0 { // Only compare the moves that were tried
+				moves = moves[:len(round)]
+				copy(totals, round)
+			}
+			break
+		}
+		for i, score := range round {
+			totals[i] += score
+		}
+	}
+
+	best := 0
+	for i := range moves {
+		if totals[i] > totals[best] {
+			best = i
+		}
+	}
+	if len(moves) == 0 || moves[best] == "" {
+		return nil
+	}
+	return []string{moves[best]}
+}
+
+// candidateMoves lists what the bot can play right now: every affordable card
+// in every lane, troops at the nearest and furthest cells they may be deployed
+// to, and cell spells on every cell holding an enemy troop
+func candidateMoves(v *View) []string {
+	var moves []string
+	tried := map[string]bool{}
+	for _, card := range v.Hand() {
+		if tried[card] || float64(v.Catalog().CardMana(card)) > v.Mana() {
+			continue
+		}
+		tried[card] = true
+		for _, lane := range v.Layout().LaneIDs() {
+			if v.Catalog().Troop(card) != nil {
+				moves = append(moves, fmt.Sprintf("%s-%s-1", card, lane))
+				if limit := v.DeployLimit(lane); limit > 1 {
+					moves = append(moves, fmt.Sprintf("%s-%s-%d", card, lane, limit))
+				}
+				continue
+			}
+			if !v.Catalog().Spell(card).TargetsCell() {
+				moves = append(moves, fmt.Sprintf("%s-%s", card, lane))
+				continue
+			}
+			targets := map[int]bool{}
+			for _, t := range v.Troops() {
+				if cell := v.Cell(t); t.Player == v.Opponent() && t.Lane == lane && !targets[cell] {
+					targets[cell] = true
+					moves = append(moves, fmt.Sprintf("%s-%s-%d", card, lane, cell))
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// rollout plays move on a copy of the match, then insaneHorizon of random
+// play seeded by seed, and scores the bot's position at the end. The copy
+// rolls with seed too, so it cannot foresee the match's own rolls.
+func rollout(v *View, move string, seed uint64) float64 {
+	s := v.state.Clone()
+	s.Reseed(seed)
+	rng := rand.New(rand.NewPCG(seed, ^seed))
+	var inputs []engine.Input
+	if move != "" {
+		if cmd, err := engine.ParseCommand(s, v.player, move); err == nil {
+			inputs = append(inputs, engine.Input{Player: v.player, Command: cmd})
+		}
+	}
+
+	every := int(playoutEvery / engine.TickDuration)
+	end := s.Tick + int(insaneHorizon/engine.TickDuration)
+	for s.Tick < end && !s.Over() {
+		engine.Step(s, inputs)
+		inputs = nil
+		if s.Tick%every == 0 {
+			for player := 1; player <= 2; player++ {
+				if in, ok := randomPlay(s, player, rng); ok {
+					inputs = append(inputs, in)
+				}
+			}
+		}
+	}
+	return score(s, v.player)
+}
+
+// randomPlay is how both sides play during a rollout: half the time, a random
+// affordable card from the hand in a random lane
+func randomPlay(s *engine.State, player int, rng *rand.Rand) (engine.Input, bool) {
+	p := s.Player(player)
+	if rng.IntN(2) == 0 || len(p.Hand) == 0 {
+		return engine.Input{}, false
+	}
+	card := p.Hand[rng.IntN(len(p.Hand))]
+	if float64(s.Catalog.CardMana(card)) > p.Mana {
+		return engine.Input{}, false
+	}
+	lanes := s.Layout.LaneIDs()
+	text := card + "-" + lanes[rng.IntN(len(lanes))]
+	if sp := s.Catalog.Spell(card); sp != nil && sp.TargetsCell() {
+		text += fmt.Sprintf("-%d", 1+rng.IntN(s.Layout.LaneLength))
+	}
+	cmd, err := engine.ParseCommand(s, player, text)
+	if err != nil {
+		return engine.Input{}, false
+	}
+	return engine.Input{Player: player, Command: cmd}, true
+}
+
+// score rates a position for player: the difference in the share of tower HP
+// each side has left, or a decided result, which outweighs any difference
+func score(s *engine.State, player int) float64 {
+	if s.Over() {
+		switch s.Winner {
+		case player:
+			return 2
+		case 0:
+			return 0
+		default:
+			return -2
+		}
+	}
+	return engine.TowerHPShare(s, player) - engine.TowerHPShare(s, 3-player)
+}
@@ -0,0 +1,86 @@
+package bots
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// The original difficulties ignore the board: once they have 5 mana they
+// send a troop card from their hand down a lane
+func init() {
+	Register("easy", func(*rand.Rand) Strategy { return easy{} })
+	Register("medium", func(*rand.Rand) Strategy { return medium{} })
+	Register("hard", func(rng *rand.Rand) Strategy { return hard{rng: rng} })
+}
+
+// easy plays its first troop card down the first guard lane every 7 seconds
+type easy struct{}
+
+func (easy) Delay() time.Duration { return 7 * time.Second }
+
+func (easy) Decide(v *View) []string {
+	troops := readyTroops(v)
+	if len(troops) == 0 {
+		return nil
+	}
+	return []string{troops[0] + "-" + guardLanes(v)[0]}
+}
+
+// medium plays its first troop card down the King's lane every 4 seconds
+type medium struct{}
+
+func (medium) Delay() time.Duration { return 4 * time.Second }
+
+func (medium) Decide(v *View) []string {
+	troops := readyTroops(v)
+	if len(troops) == 0 {
+		return nil
+	}
+	return []string{troops[0] + "-" + v.Layout().KingLane()}
+}
+
+// hard plays a random troop card down a random guard lane every 2 seconds
+type hard struct {
+	rng *rand.Rand
+}
+
+func (hard) Delay() time.Duration { return 2 * time.Second }
+
+func (h hard) Decide(v *View) []string {
+	troops := readyTroops(v)
+	if len(troops) == 0 {
+		return nil
+	}
+	lanes := guardLanes(v)
+	return []string{troops[h.rng.IntN(len(troops))] + "-" + lanes[h.rng.IntN(len(lanes))]}
+}
+
+// readyTroops lists the troop cards in the bot's hand, or none while it has
+// less than 5 mana
+func readyTroops(v *View) []string {
+	if v.Mana() < 5 {
+		return nil
+	}
+	var troops []string
+	for _, card := range v.Hand() {
+		if v.Catalog().Troop(card) != nil {
+			troops = append(troops, card)
+		}
+	}
+	return troops
+}
+
+// guardLanes lists the lanes defended by a guard tower, or every lane if the
+// layout has none
+func guardLanes(v *View) []string {
+	var lanes []string
+	for _, lane := range v.Layout().Lanes {
+		if lane.Tower == "Guard" {
+			lanes = append(lanes, lane.ID)
+		}
+	}
+	if len(lanes) == 0 {
+		return v.Layout().LaneIDs()
+	}
+	return lanes
+}
@@ -0,0 +1,121 @@
+package bots
+
+import (
+	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
+)
+
+// View is what a bot can see of a match, from one player's side. It reads a
+// private copy of the state taken when the view was made, so a strategy can
+// think for as long as it likes without holding up the game, and nothing it
+// does can change the match.
+type View struct {
+	state  *engine.State
+	player int
+}
+
+// NewView copies the state for a bot playing as player
+func NewView(s *engine.State, player int) *View {
+	return &View{state: s.Clone(), player: player}
+}
+
+// Player is the bot's player number
+func (v *View) Player() int {
+	return v.player
+}
+
+// Opponent is the other player's number
+func (v *View) Opponent() int {
+	return 3 - v.player
+}
+
+// Mana is the bot's current mana
+func (v *View) Mana() float64 {
+	return v.state.Player(v.player).Mana
+}
+
+// ManaCap is the most mana a player can hold
+func (v *View) ManaCap() float64 {
+	return v.state.Rules.ManaCap
+}
+
+// Level is the bot's level after scaling, which its new troops are built with
+func (v *View) Level() int {
+	return v.state.Player(v.player).Level
+}
+
+// Hand lists the cards the bot can play
+func (v *View) Hand() []string {
+	return append([]string(nil), v.state.Player(v.player).Hand...)
+}
+
+// Next is the card that replaces the next one played
+func (v *View) Next() string {
+	return v.state.Player(v.player).Next()
+}
+
+// Catalog holds the card and tower definitions of the match
+func (v *View) Catalog() *engine.Catalog {
+	return v.state.Catalog
+}
+
+// Layout is the arena the match is played on
+func (v *View) Layout() *engine.Layout {
+	return v.state.Layout
+}
+
+// Tower returns a player's tower in a lane; ok is false if the lane has none
+func (v *View) Tower(player int, lane string) (t engine.Tower, ok bool) {
+	tower := v.state.Towers[player][lane]
+	if tower == nil {
+		return engine.Tower{}, false
+	}
+	return *tower, true
+}
+
+// Troops lists every troop on the map, in deployment order
+func (v *View) Troops() []engine.Troop {
+	troops := make([]engine.Troop, 0, len(v.state.Troops))
+	for _, t := range v.state.Troops {
+		if t.Alive {
+			troops = append(troops, *t)
+		}
+	}
+	return troops
+}
+
+// Cell returns where a troop stands, counted from the bot's own towers the
+// same way deploy cells are: 1 is the cell next to them
+func (v *View) Cell(t engine.Troop) int {
+	if v.player == 1 {
+		return t.Position + 1
+	}
+	return v.state.Layout.LaneLength - t.Position
+}
+
+// Crowns is how many crowns a player has taken
+func (v *View) Crowns(player int) int {
+	return engine.Crowns(v.state, player)
+}
+
+// Elapsed is the match time played so far
+func (v *View) Elapsed() time.Duration {
+	return v.state.Elapsed()
+}
+
+// TimeLeft is the time until regulation, or overtime, ends
+func (v *View) TimeLeft() time.Duration {
+	return v.state.TimeLeft()
+}
+
+// Over reports whether the match has ended
+func (v *View) Over() bool {
+	return v.state.Over()
+}
+
+// DeployLimit is the furthest cell the bot may deploy to in a lane, counted
+// from its own towers
+func (v *View) DeployLimit(lane string) int {
+	return v.state.DeployLimit(v.player, lane)
+}
@@ -0,0 +1,196 @@
+package engine
+
+import "fmt"
+
+// Ability is the behavior behind one ability kind. Every hook is optional and
+// receives the AbilityDef from the catalog, so the same kind can be tuned per
+// troop in data. Hooks run in the order the abilities are listed for a troop.
+type Ability struct {
+	Validate     func(c *Catalog, a AbilityDef) error           // Checks the catalog entry
+	OnDeploy     func(s *State, t *Troop, a AbilityDef)         // Troop entered the map
+	OnTick       func(s *State, t *Troop, a AbilityDef)         // Start of every tick the troop is alive
+	OnAttack     func(s *State, t *Troop, a AbilityDef, h *Hit) // Troop is about to deal a blow
+	OnDeath      func(s *State, t *Troop, a AbilityDef)         // Troop was killed
+	OnReachTower func(s *State, t *Troop, a AbilityDef) bool    // Troop reached the enemy towers (see reachedTower); true uses it up
+}
+
+// Hit is a single blow about to be dealt by a troop. Exactly one of Target
+// and Tower is set. OnAttack hooks may change Damage before it is applied.
+type Hit struct {
+	Target *Troop
+	Tower  *Tower
+	Damage int
+}
+
+// abilities maps ability kinds to their behavior
+var abilities = map[string]*Ability{}
+
+// RegisterAbility makes an ability kind available to catalogs. It is meant to
+// be called from init functions, before any catalog is loaded.
+func RegisterAbility(kind string, a *Ability) {
+	abilities[kind] = a
+}
+
+// validateAbility checks one ability entry of a troop
+func validateAbility(c *Catalog, a AbilityDef) error {
+	ab := abilities[a.Kind]
+	if ab == nil {
+		return fmt.Errorf("unknown ability %q", a.Kind)
+	}
+	if ab.Validate != nil {
+		return ab.Validate(c, a)
+	}
+	return nil
+}
+
+// onDeploy runs the deploy hooks of a troop's abilities
+func onDeploy(s *State, t *Troop) {
+	for _, a := range s.Catalog.Troop(t.Type).Abilities {
+		if h := abilities[a.Kind].OnDeploy; h != nil {
+			h(s, t, a)
+		}
+	}
+}
+
+// onTick runs the per-tick hooks of a troop's abilities
+func onTick(s *State, t *Troop) {
+	for _, a := range s.Catalog.Troop(t.Type).Abilities {
+		if h := abilities[a.Kind].OnTick; h != nil {
+			h(s, t, a)
+		}
+	}
+}
+
+// onAttack runs the attack hooks of a troop's abilities
+func onAttack(s *State, t *Troop, hit *Hit) {
+	for _, a := range s.Catalog.Troop(t.Type).Abilities {
+		if h := abilities[a.Kind].OnAttack; h != nil {
+			h(s, t, a, hit)
+		}
+	}
+}
+
+// onDeath runs the death hooks of a troop's abilities
+func onDeath(s *State, t *Troop) {
+	for _, a := range s.Catalog.Troop(t.Type).Abilities {
+		if h := abilities[a.Kind].OnDeath; h != nil {
+			h(s, t, a)
+		}
+	}
+}
+
+// onReachTower runs the reach-tower hooks and reports whether any of them
+// used the troop up
+func onReachTower(s *State, t *Troop) bool {
+	usedUp := false
+	for _, a := range s.Catalog.Troop(t.Type).Abilities {
+		if h := abilities[a.Kind].OnReachTower; h != nil && h(s, t, a) {
+			usedUp = true
+		}
+	}
+	return usedUp
+}
+
+// --- Built-in abilities ---
+
+func init() {
+	// tower_heal restores Amount HP to the owner's weakest standing tower
+	// when the troop comes within range of the enemy towers, using the troop up
+	RegisterAbility("tower_heal", &Ability{
+		Validate: needPositive("amount", func(a AbilityDef) int { return a.Amount }),
+		OnReachTower: func(s *State, t *Troop, a AbilityDef) bool {
+			lowestHP := 0
+			healLane := ""
+			for _, lane := range s.Layout.LaneIDs() {
+				tower := s.Towers[t.Player][lane]
+				if tower.HP > 0 && (healLane == "" || tower.HP < lowestHP) {
+					lowestHP = tower.HP
+					healLane = lane
+				}
+			}
+			if healLane != "" {
+				s.Towers[t.Player][healLane].HP += a.Amount
+				s.emit(Healed{Player: t.Player, Lane: healLane, Type: t.Type, Amount: a.Amount})
+			}
+			return true
+		},
+	})
+
+	// splash deals Percent of each blow to the other enemy troops within
+	// Radius cells of the target
+	RegisterAbility("splash", &Ability{
+		Validate: needPositive("percent", func(a AbilityDef) int { return a.Percent }),
+		OnAttack: func(s *State, t *Troop, a AbilityDef, h *Hit) {
+			if h.Target == nil {
+				return
+			}
+			splash := h.Damage * a.Percent / 100
+			for _, e := range s.Troops {
+				if e != h.Target && e.Alive && e.Player != t.Player && e.Lane == h.Target.Lane &&
+					distance(e.Position, h.Target.Position) <= a.Radius {
+					damageTroop(s, e, splash, t.ID)
+				}
+			}
+		},
+	})
+
+	// spawn_on_death leaves Count troops of type Troop where the troop died
+	RegisterAbility("spawn_on_death", &Ability{
+		Validate: func(c *Catalog, a AbilityDef) error {
+			if c.Troop(a.Troop) == nil {
+				return fmt.Errorf("spawn_on_death: unknown troop %q", a.Troop)
+			}
+			if a.Count <= 0 {
+				return fmt.Errorf("spawn_on_death: count must be positive")
+			}
+			return nil
+		},
+		OnDeath: func(s *State, t *Troop, a AbilityDef) {
+			for i := 0; i < a.Count; i++ {
+				spawnTroop(s, t.Player, a.Troop, t.Lane, t.Position)
+			}
+		},
+	})
+
+	// charge adds Percent damage to the first blow after walking Cells cells
+	RegisterAbility("charge", &Ability{
+		Validate: needPositive("percent", func(a AbilityDef) int { return a.Percent }),
+		OnAttack: func(s *State, t *Troop, a AbilityDef, h *Hit) {
+			if t.Walked >= a.Cells {
+				h.Damage += h.Damage * a.Percent / 100
+			}
+		},
+	})
+
+	// shield absorbs the first Amount points of damage the troop takes
+	RegisterAbility("shield", &Ability{
+		Validate: needPositive("amount", func(a AbilityDef) int { return a.Amount }),
+		OnDeploy: func(s *State, t *Troop, a AbilityDef) {
+			t.Shield += a.Amount
+		},
+	})
+
+	// lifesteal heals the troop for Percent of the damage it deals, up to its max HP
+	RegisterAbility("lifesteal", &Ability{
+		Validate: needPositive("percent", func(a AbilityDef) int { return a.Percent }),
+		OnAttack: func(s *State, t *Troop, a AbilityDef, h *Hit) {
+			if !t.Alive {
+				return
+			}
+			t.HP += h.Damage * a.Percent / 100
+			if t.HP > t.MaxHP {
+				t.HP = t.MaxHP
+			}
+		},
+	})
+}
+
+// needPositive builds a validator requiring one ability parameter to be positive
+func needPositive(name string, field func(AbilityDef) int) func(*Catalog, AbilityDef) error {
+	return func(c *Catalog, a AbilityDef) error {
+		if field(a) <= 0 {
+			return fmt.Errorf("%s: %s must be positive", a.Kind, name)
+		}
+		return nil
+	}
+}
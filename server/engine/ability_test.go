@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+// ability returns the catalog entry of a troop's ability of the given kind
+func ability(t *testing.T, s *State, troop, kind string) AbilityDef {
+	t.Helper()
+	for _, a := range s.Catalog.Troop(troop).Abilities {
+		if a.Kind == kind {
+			return a
+		}
+	}
+	t.Fatalf("troop %s has no %s ability", troop, kind)
+	return AbilityDef{}
+}
+
+func TestSplash(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	splash := ability(t, s, "W", "splash")
+	wizard := spawnTroop(s, 1, "W", "L", 2)
+	target := spawnTroop(s, 2, "G", "L", 4)
+	near := spawnTroop(s, 2, "G", "L", 4+splash.Radius)
+	far := spawnTroop(s, 2, "G", "L", 4+splash.Radius+1)
+	own := spawnTroop(s, 1, "G", "L", 4)
+
+	onAttack(s, wizard, &Hit{Target: target, Damage: 100})
+	if want := near.MaxHP - 100*splash.Percent/100; near.HP != want {
+		t.Errorf("troop next to the target has %d HP, want %d", near.HP, want)
+	}
+	if target.HP != target.MaxHP {
+		t.Error("splash hit the target itself; the blow does that")
+	}
+	if far.HP != far.MaxHP || own.HP != own.MaxHP {
+		t.Error("splash hit a troop out of its radius or of its own side")
+	}
+}
+
+func TestSpawnOnDeath(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	spawn := ability(t, s, "G", "spawn_on_death")
+	golem := spawnTroop(s, 2, "G", "R", 5)
+
+	damageTroop(s, golem, golem.HP, 0)
+	n := 0
+	for _, troop := range s.Troops {
+		if troop.Alive && troop.Type == spawn.Troop {
+			n++
+			if troop.Player != 2 || troop.Lane != "R" || troop.Position != 5 {
+				t.Errorf("spawned %+v, want it where player 2's Golem died", troop)
+			}
+		}
+	}
+	if n != spawn.Count {
+		t.Fatalf("Golem left %d troops, want %d", n, spawn.Count)
+	}
+}
+
+func TestCharge(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	charge := ability(t, s, "I", "charge")
+	prince := spawnTroop(s, 1, "I", "L", 0)
+
+	prince.Walked = charge.Cells - 1
+	hit := Hit{Tower: s.Towers[2]["L"], Damage: 100}
+	onAttack(s, prince, &hit)
+	if hit.Damage != 100 {
+		t.Fatalf("blow after %d cells deals %d, want no charge bonus", prince.Walked, hit.Damage)
+	}
+
+	prince.Walked = charge.Cells
+	onAttack(s, prince, &hit)
+	if want := 100 + 100*charge.Percent/100; hit.Damage != want {
+		t.Fatalf("charged blow deals %d, want %d", hit.Damage, want)
+	}
+}
+
+func TestShield(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	shield := ability(t, s, "R", "shield")
+	rook := spawnTroop(s, 1, "R", "L", 0)
+	if rook.Shield != shield.Amount {
+		t.Fatalf("Rook deployed with a shield of %d, want %d", rook.Shield, shield.Amount)
+	}
+
+	damageTroop(s, rook, shield.Amount-10, 0)
+	if rook.HP != rook.MaxHP {
+		t.Fatalf("Rook lost HP while its shield held")
+	}
+	damageTroop(s, rook, 30, 0)
+	if rook.Shield != 0 || rook.HP != rook.MaxHP-20 {
+		t.Fatalf("Rook has %d shield and %d of %d HP, want 0 and 20 damage", rook.Shield, rook.HP, rook.MaxHP)
+	}
+}
+
+func TestLifesteal(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	lifesteal := ability(t, s, "V", "lifesteal")
+	vampire := spawnTroop(s, 1, "V", "L", 0)
+	target := spawnTroop(s, 2, "G", "L", 1)
+
+	vampire.HP = 1
+	onAttack(s, vampire, &Hit{Target: target, Damage: 100})
+	if want := 1 + 100*lifesteal.Percent/100; vampire.HP != want {
+		t.Fatalf("Vampire has %d HP after stealing life, want %d", vampire.HP, want)
+	}
+	onAttack(s, vampire, &Hit{Target: target, Damage: 10 * vampire.MaxHP})
+	if vampire.HP != vampire.MaxHP {
+		t.Fatalf("Vampire has %d HP, want no more than its max of %d", vampire.HP, vampire.MaxHP)
+	}
+}
+
+func TestTowerHealPicksWeakestStanding(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	heal := ability(t, s, "Q", "tower_heal")
+	s.Towers[1]["L"].HP = 0 // Fallen towers are not healed
+	s.Towers[1]["R"].HP -= 500
+	s.Towers[1]["C"].HP -= 100
+	hp := s.Towers[1]["R"].HP
+	queen := spawnTroop(s, 1, "Q", "C", 4)
+
+	if !onReachTower(s, queen) {
+		t.Fatal("Queen was not used up by her heal")
+	}
+	if s.Towers[1]["R"].HP != hp+heal.Amount {
+		t.Fatalf("weakest tower has %d HP, want %d", s.Towers[1]["R"].HP, hp+heal.Amount)
+	}
+	if s.Towers[1]["L"].HP != 0 {
+		t.Fatal("Queen healed a fallen tower")
+	}
+
+	// The event names the troop that healed
+	ev, ok := s.events[len(s.events)-1].(Healed)
+	if !ok || ev.Type != "Q" {
+		t.Fatalf("heal reported as %#v, want a Healed event from the Queen", s.events[len(s.events)-1])
+	}
+	if msg := s.Describe(ev); !strings.HasPrefix(msg, s.Catalog.CardName("Q")+" healed") {
+		t.Fatalf("heal described as %q", msg)
+	}
+}
@@ -0,0 +1,297 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TroopDef is the catalog entry for one troop type
+type TroopDef struct {
+	ID             string       `json:"id"`              // Command letter, e.g. "K"
+	Name           string       `json:"name"`            // Display name, e.g. "Knight"
+	HP             int          `json:"hp"`              // Base HP at level 0
+	Atk            int          `json:"atk"`             // Base attack at level 0
+	Def            int          `json:"def"`             // Base defense at level 0
+	Mana           int          `json:"mana"`            // Mana cost to deploy
+	Speed          float64      `json:"speed"`           // Cells moved per second
+	Range          int          `json:"range"`           // How many cells away the troop can hit (1 = melee)
+	AttackCooldown float64      `json:"attack_cooldown"` // Seconds between attacks
+	Abilities      []AbilityDef `json:"abilities"`       // Special behaviors
+	Description    string       `json:"description"`     // Shown in the command help
+}
+
+// AbilityDef configures one special behavior of a troop
+type AbilityDef struct {
+	Kind    string `json:"kind"`              // Registered ability kind, e.g. "tower_heal"
+	Amount  int    `json:"amount,omitempty"`  // Flat strength (HP healed, shield points)
+	Percent int    `json:"percent,omitempty"` // Relative strength in percent
+	Radius  int    `json:"radius,omitempty"`  // Cells around the target that are affected
+	Troop   string `json:"troop,omitempty"`   // Troop type to spawn
+	Count   int    `json:"count,omitempty"`   // Number of troops to spawn
+	Cells   int    `json:"cells,omitempty"`   // Cells to walk before a charge is ready
+}
+
+// TowerDef is the catalog entry for one tower kind
+type TowerDef struct {
+	ID   string  `json:"id"`   // "King" or "Guard"
+	Name string  `json:"name"` // Display name
+	HP   int     `json:"hp"`
+	Atk  int     `json:"atk"`
+	Def  int     `json:"def"`
+	Crit float64 `json:"crit"` // Critical hit chance in percent
+
+	Range          int     `json:"range"`           // How many cells into the lane the tower reaches
+	AttackCooldown float64 `json:"attack_cooldown"` // Seconds between shots
+	Targeting      string  `json:"targeting"`       // "nearest" or "lowest_hp"
+}
+
+// SpellDef is the catalog entry for one spell card
+type SpellDef struct {
+	ID          string  `json:"id"`                 // Command letter, e.g. "F"
+	Name        string  `json:"name"`               // Display name, e.g. "Fireball"
+	Mana        int     `json:"mana"`               // Mana cost to cast
+	Effect      string  `json:"effect"`             // "fireball", "freeze", "rage" or "arrows"
+	Damage      int     `json:"damage,omitempty"`   // Damage dealt by fireball and arrows
+	Radius      int     `json:"radius,omitempty"`   // Cells around the target hit by a fireball
+	Duration    float64 `json:"duration,omitempty"` // Seconds a freeze or rage lasts
+	Boost       int     `json:"boost,omitempty"`    // Rage speed and attack bonus in percent
+	Description string  `json:"description"`        // Shown in the command help
+}
+
+// TargetsCell reports whether the spell is aimed at a single cell rather
+// than a whole lane
+func (sp *SpellDef) TargetsCell() bool {
+	return sp.Effect == "fireball"
+}
+
+// Catalog holds every troop, spell, tower and layout definition used by a match
+type Catalog struct {
+	Troops  []TroopDef `json:"troops"`
+	Spells  []SpellDef `json:"spells"`
+	Towers  []TowerDef `json:"towers"`
+	Layouts []Layout   `json:"layouts"` // The first layout is the default
+
+	DefaultDeck []string `json:"default_deck"` // Deck for players and bots without one of their own
+
+	troops map[string]*TroopDef
+	spells map[string]*SpellDef
+	towers map[string]*TowerDef
+	raw    []byte // Compacted JSON the catalog was parsed from
+}
+
+// LoadCatalog reads a catalog from a JSON file and validates it
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(data)
+}
+
+// ParseCatalog decodes a catalog from JSON and validates it
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	var raw bytes.Buffer
+	if err := json.Compact(&raw, data); err != nil {
+		return nil, err
+	}
+	c.raw = raw.Bytes()
+	return &c, nil
+}
+
+// JSON returns the catalog's definition as compact JSON
+func (c *Catalog) JSON() []byte {
+	return c.raw
+}
+
+// Hash returns a SHA-256 fingerprint of the catalog's definition, so two
+// servers can tell whether they play by the same rules
+func (c *Catalog) Hash() string {
+	sum := sha256.Sum256(c.raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// init builds the lookup indexes and checks the catalog for consistency
+func (c *Catalog) init() error {
+	c.troops = make(map[string]*TroopDef)
+	c.spells = make(map[string]*SpellDef)
+	c.towers = make(map[string]*TowerDef)
+
+	if len(c.Troops) == 0 {
+		return fmt.Errorf("catalog has no troops")
+	}
+	for i := range c.Troops {
+		t := &c.Troops[i]
+		t.ID = strings.ToUpper(t.ID)
+		switch {
+		case t.ID == "" || strings.ContainsAny(t.ID, "- "):
+			return fmt.Errorf("troop %d: invalid id %q", i, t.ID)
+		case c.troops[t.ID] != nil:
+			return fmt.Errorf("troop %s: duplicate id", t.ID)
+		case t.Name == "":
+			return fmt.Errorf("troop %s: missing name", t.ID)
+		case t.HP <= 0 || t.Atk < 0 || t.Def < 0:
+			return fmt.Errorf("troop %s: hp must be positive and atk/def not negative", t.ID)
+		case t.Mana <= 0:
+			return fmt.Errorf("troop %s: mana cost must be positive", t.ID)
+		case t.Speed <= 0:
+			return fmt.Errorf("troop %s: speed must be positive", t.ID)
+		case t.Range < 1:
+			return fmt.Errorf("troop %s: range must be at least 1", t.ID)
+		case t.AttackCooldown <= 0:
+			return fmt.Errorf("troop %s: attack_cooldown must be positive", t.ID)
+		}
+		c.troops[t.ID] = t
+	}
+
+	// Abilities may refer to other troops, so they are checked once all are known
+	for _, t := range c.Troops {
+		for _, a := range t.Abilities {
+			if err := validateAbility(c, a); err != nil {
+				return fmt.Errorf("troop %s: %v", t.ID, err)
+			}
+		}
+	}
+
+	for i := range c.Spells {
+		sp := &c.Spells[i]
+		sp.ID = strings.ToUpper(sp.ID)
+		switch {
+		case sp.ID == "" || strings.ContainsAny(sp.ID, "- "):
+			return fmt.Errorf("spell %d: invalid id %q", i, sp.ID)
+		case c.spells[sp.ID] != nil || c.troops[sp.ID] != nil:
+			return fmt.Errorf("spell %s: duplicate id", sp.ID)
+		case sp.Name == "":
+			return fmt.Errorf("spell %s: missing name", sp.ID)
+		case sp.Mana <= 0:
+			return fmt.Errorf("spell %s: mana cost must be positive", sp.ID)
+		}
+		switch sp.Effect {
+		case "fireball", "arrows":
+			if sp.Damage <= 0 || sp.Radius < 0 {
+				return fmt.Errorf("spell %s: %s needs positive damage and a radius of at least 0", sp.ID, sp.Effect)
+			}
+		case "freeze", "rage":
+			if sp.Duration <= 0 {
+				return fmt.Errorf("spell %s: %s needs a positive duration", sp.ID, sp.Effect)
+			}
+			if sp.Effect == "rage" && sp.Boost <= 0 {
+				return fmt.Errorf("spell %s: rage needs a positive boost", sp.ID)
+			}
+		default:
+			return fmt.Errorf("spell %s: unknown effect %q", sp.ID, sp.Effect)
+		}
+		c.spells[sp.ID] = sp
+	}
+
+	for i := range c.Towers {
+		t := &c.Towers[i]
+		switch {
+		case c.towers[t.ID] != nil:
+			return fmt.Errorf("tower %s: duplicate id", t.ID)
+		case t.HP <= 0 || t.Atk < 0 || t.Def < 0:
+			return fmt.Errorf("tower %s: hp must be positive and atk/def not negative", t.ID)
+		case t.Crit < 0 || t.Crit > 100:
+			return fmt.Errorf("tower %s: crit must be between 0 and 100", t.ID)
+		case t.Range < 1:
+			return fmt.Errorf("tower %s: range must be at least 1", t.ID)
+		case t.AttackCooldown <= 0:
+			return fmt.Errorf("tower %s: attack_cooldown must be positive", t.ID)
+		case t.Targeting != "nearest" && t.Targeting != "lowest_hp":
+			return fmt.Errorf("tower %s: targeting must be \"nearest\" or \"lowest_hp\"", t.ID)
+		}
+		c.towers[t.ID] = t
+	}
+	for _, id := range []string{"King", "Guard"} {
+		if c.towers[id] == nil {
+			return fmt.Errorf("catalog is missing the %s tower", id)
+		}
+	}
+
+	if len(c.Layouts) == 0 {
+		return fmt.Errorf("catalog has no layouts")
+	}
+	for i := range c.Layouts {
+		if err := c.Layouts[i].validate(c); err != nil {
+			return err
+		}
+		if c.Layout(c.Layouts[i].Name) != &c.Layouts[i] {
+			return fmt.Errorf("layout %s: duplicate name", c.Layouts[i].Name)
+		}
+	}
+
+	if err := c.ValidateDeck(c.DefaultDeck); err != nil {
+		return fmt.Errorf("default_deck: %v", err)
+	}
+	return nil
+}
+
+// Layout returns the layout with the given name, or nil if it does not exist
+func (c *Catalog) Layout(name string) *Layout {
+	for i := range c.Layouts {
+		if c.Layouts[i].Name == name {
+			return &c.Layouts[i]
+		}
+	}
+	return nil
+}
+
+// Troop returns the definition of a troop type, or nil if it does not exist
+func (c *Catalog) Troop(id string) *TroopDef {
+	return c.troops[id]
+}
+
+// Spell returns the definition of a spell, or nil if it does not exist
+func (c *Catalog) Spell(id string) *SpellDef {
+	return c.spells[id]
+}
+
+// Tower returns the definition of a tower kind, or nil if it does not exist
+func (c *Catalog) Tower(id string) *TowerDef {
+	return c.towers[id]
+}
+
+// TroopIDs returns all troop ids in catalog order
+func (c *Catalog) TroopIDs() []string {
+	ids := make([]string, len(c.Troops))
+	for i, t := range c.Troops {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// SpellIDs returns all spell ids in catalog order
+func (c *Catalog) SpellIDs() []string {
+	ids := make([]string, len(c.Spells))
+	for i, sp := range c.Spells {
+		ids[i] = sp.ID
+	}
+	return ids
+}
+
+// HasAbility reports whether the troop has an ability of the given kind
+func (t *TroopDef) HasAbility(kind string) bool {
+	_, ok := t.Ability(kind)
+	return ok
+}
+
+// Ability returns the troop's ability of the given kind, if it has one
+func (t *TroopDef) Ability(kind string) (AbilityDef, bool) {
+	for _, a := range t.Abilities {
+		if a.Kind == kind {
+			return a, true
+		}
+	}
+	return AbilityDef{}, false
+}
@@ -0,0 +1,221 @@
+package engine
+
+import "time"
+
+// calculateDamage computes damage using CRIT chance and defense, and reports
+// whether the hit was critical
+func calculateDamage(s *State, atk int, critChance float64, def int) (int, bool) {
+	// Check for critical hit
+	isCrit := s.rng.Float64()*100 < critChance
+	baseDamage := atk
+	if isCrit {
+		baseDamage = int(float64(atk) * 1.2)
+	}
+
+	damage := baseDamage - def
+	if damage < 0 {
+		return 0, isCrit
+	}
+	return damage, isCrit
+}
+
+// findTarget returns the enemy troop a troop should attack this tick. A troop
+// stays engaged with its current target until one of them dies; otherwise it
+// picks the closest enemy in range, preferring the earliest deployed. Enemies
+// that cannot hurt each other are ignored, as neither of them could ever win.
+func findTarget(s *State, t *Troop, attackRange int) *Troop {
+	var closest *Troop
+	best := attackRange + 1
+	for _, e := range s.Troops {
+		if !e.Alive || e.Player == t.Player || e.Lane != t.Lane || harmless(s, t, e) {
+			continue
+		}
+		dist := distance(e.Position, t.Position)
+		if dist > attackRange {
+			continue
+		}
+		if e.ID == t.Target {
+			return e
+		}
+		if dist < best {
+			closest, best = e, dist
+		}
+	}
+	return closest
+}
+
+// harmless reports whether two troops' blows, with any Rage on them, are both
+// too weak to get through the other's defense
+func harmless(s *State, a, b *Troop) bool {
+	return int(boosted(float64(a.Atk), rageBoost(s, a))) <= b.Def &&
+		int(boosted(float64(b.Atk), rageBoost(s, b))) <= a.Def
+}
+
+// secondsToTicks converts a duration in seconds to whole ticks, rounding up
+// and at least one. The conversion goes through time.Duration so that values
+// like 1.1s do not pick up an extra tick from floating point error.
+func secondsToTicks(seconds float64) int {
+	d := time.Duration(seconds * float64(time.Second))
+	ticks := int((d + TickDuration - 1) / TickDuration)
+	if ticks < 1 {
+		return 1
+	}
+	return ticks
+}
+
+// updateTroops resolves troop attacks and movement for one tick.
+//
+// Troops are handled in deployment order so the outcome never depends on map
+// iteration or timing. All troop-vs-troop attacks are declared before any
+// damage is applied, so two troops that hit each other in the same tick both
+// land their blow even if both die. Troops that are not fighting then advance;
+// an enemy within range blocks the way, so opposing troops can only walk past
+// each other when neither can hurt the other, while any number of friendly
+// troops may share a cell.
+func updateTroops(s *State) {
+	type blow struct {
+		attacker *Troop
+		hit      Hit
+	}
+	var blows []blow
+	engaged := make(map[*Troop]bool)
+
+	for _, t := range s.Troops {
+		if !t.Alive {
+			continue
+		}
+		t.Age++
+		onTick(s, t)
+		if frozen(s, t) {
+			engaged[t] = true // Frozen troops neither fight nor move
+			continue
+		}
+		if t.Cooldown > 0 {
+			t.Cooldown--
+		}
+
+		def := s.Catalog.Troop(t.Type)
+		atk := int(boosted(float64(t.Atk), rageBoost(s, t)))
+
+		// Enemy troops in range are engaged before the tower
+		if enemy := findTarget(s, t, def.Range); enemy != nil {
+			engaged[t] = true
+			t.Target = enemy.ID
+			if t.Cooldown == 0 {
+				damage, _ := calculateDamage(s, atk, 0, enemy.Def)
+				blows = append(blows, blow{t, Hit{Target: enemy, Damage: damage}})
+				t.Cooldown = secondsToTicks(def.AttackCooldown)
+			}
+			continue
+		}
+		t.Target = 0
+
+		enemyPlayer := 3 - t.Player
+		enemyTower := s.Towers[enemyPlayer][t.Lane]
+		if enemyTower.HP > 0 && distance(t.Position, s.towerCell(enemyPlayer)) <= def.Range {
+			engaged[t] = true
+			if t.Cooldown == 0 {
+				damage, _ := calculateDamage(s, atk, 0, enemyTower.Def)
+				hit := Hit{Tower: enemyTower, Damage: damage}
+				onAttack(s, t, &hit)
+				damageTower(s, enemyPlayer, t.Lane, hit.Damage)
+				t.Walked = 0
+				t.Cooldown = secondsToTicks(def.AttackCooldown)
+			}
+		}
+	}
+
+	// Blows land even if the attacker falls in the same exchange
+	for _, b := range blows {
+		onAttack(s, b.attacker, &b.hit)
+		b.attacker.Walked = 0
+		damageTroop(s, b.hit.Target, b.hit.Damage, b.attacker.ID)
+	}
+
+	for _, t := range s.Troops {
+		if !t.Alive || engaged[t] {
+			continue
+		}
+		def := s.Catalog.Troop(t.Type)
+
+		// Advance one cell for every full cell of distance covered
+		t.Progress += boosted(def.Speed, rageBoost(s, t)) * TickDuration.Seconds()
+		for t.Progress >= 1 && !s.atEnemyTower(t) && findTarget(s, t, def.Range) == nil {
+			t.Progress--
+			t.Position += forward(t.Player)
+			t.Walked++
+		}
+		if s.atEnemyTower(t) || findTarget(s, t, def.Range) != nil {
+			t.Progress = 0 // Blocked, so no distance is banked
+		}
+	}
+
+	var aliveTroops []*Troop
+	for _, t := range s.Troops {
+		if t.Alive {
+			aliveTroops = append(aliveTroops, t)
+		}
+	}
+	s.Troops = aliveTroops
+}
+
+// damageTroop applies damage to a troop, draining its shield first. A troop
+// brought to 0 HP dies at once and its death hooks run. Attacker is the id of
+// the troop that struck, or 0 for towers and spells.
+func damageTroop(s *State, t *Troop, damage, attacker int) {
+	if !t.Alive {
+		return
+	}
+	s.emit(TroopAttacked{Player: t.Player, Troop: t.ID, Type: t.Type, Lane: t.Lane, Attacker: attacker, Damage: damage})
+	if t.Shield > 0 {
+		absorbed := min(t.Shield, damage)
+		t.Shield -= absorbed
+		damage -= absorbed
+	}
+	t.HP -= damage
+	if t.HP <= 0 {
+		t.Alive = false
+		s.emit(TroopDied{Player: t.Player, Troop: t.ID, Type: t.Type, Lane: t.Lane, Killer: attacker})
+		onDeath(s, t)
+	}
+}
+
+// damageTower applies damage to a standing tower and reports its fall
+func damageTower(s *State, owner int, lane string, damage int) {
+	tower := s.Towers[owner][lane]
+	if tower.HP <= 0 {
+		return
+	}
+	tower.HP -= damage
+	s.emit(TowerDamaged{Player: owner, Lane: lane, Damage: damage, HP: max(tower.HP, 0)})
+	if tower.HP <= 0 {
+		s.emit(TowerDestroyed{Player: owner, Lane: lane, Kind: tower.Kind})
+	}
+}
+
+// applyCombat runs the hooks of troops that reached the enemy towers, lets
+// towers shoot, then sends troops whose tower has fallen on to the next one
+func applyCombat(s *State) {
+	// Abilities such as the Queen's heal act before the towers get to shoot,
+	// and may use the troop up
+	for _, t := range s.Troops {
+		if t.Alive && s.reachedTower(t) && onReachTower(s, t) {
+			t.Alive = false
+		}
+	}
+
+	towersShoot(s)
+
+	for _, t := range s.Troops {
+		if !t.Alive || !s.atEnemyTower(t) || s.Towers[3-t.Player][t.Lane].HP > 0 {
+			continue
+		}
+
+		// The lane's tower has fallen, so head for the next tower to attack
+		if lane := s.fallbackLane(t.Player, t.Lane); lane != t.Lane {
+			t.Lane = lane
+			t.Position = s.startCell(t.Player)
+			t.Progress = 0
+		}
+	}
+}
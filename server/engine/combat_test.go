@@ -0,0 +1,40 @@
+package engine
+
+import "testing"
+
+func TestHarmlessTroopsWalkPast(t *testing.T) {
+	// The long lane keeps both Rooks out of the towers' range
+	s := newTestState(t, "long", 1)
+	a := spawnTroop(s, 1, "R", "L", 3)
+	b := spawnTroop(s, 2, "R", "L", 4)
+	if !harmless(s, a, b) {
+		t.Fatal("two Rooks can hurt each other; pick troops that cannot")
+	}
+
+	for i := 0; i < 80; i++ {
+		Step(s, nil)
+	}
+	if a.Position <= b.Position {
+		t.Fatalf("Rooks at %d and %d did not walk past each other", a.Position, b.Position)
+	}
+	if a.HP != a.MaxHP || b.HP != b.MaxHP {
+		t.Fatal("Rooks that cannot hurt each other lost HP")
+	}
+}
+
+func TestTroopsThatCanFightBlock(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	knight := spawnTroop(s, 1, "K", "L", 3)
+	rook := spawnTroop(s, 2, "R", "L", 4)
+
+	Step(s, nil)
+	if knight.Target != rook.ID || rook.Target != knight.ID {
+		t.Fatalf("Knight and Rook target %d and %d, want each other", knight.Target, rook.Target)
+	}
+	for !s.Over() && knight.Alive && rook.Alive {
+		Step(s, nil)
+		if knight.Position >= rook.Position {
+			t.Fatalf("Knight at %d walked past the Rook at %d", knight.Position, rook.Position)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformed is returned by ParseCommand for text that is not a command at all
+var ErrMalformed = errors.New("malformed command")
+
+// Command is an action a player asks the simulation to perform
+type Command interface {
+	isCommand()
+	String() string // The command in the text form ParseCommand accepts
+}
+
+// Deploy places a new troop in a lane. Cell counts from the player's own
+// towers like a spell target; 0 deploys at the player's end of the lane.
+type Deploy struct {
+	Troop string // Troop type (P, B, R, K, I, Q)
+	Lane  string // Lane id from the layout, e.g. "L"
+	Cell  int    // Deployment cell, 1 to the player's DeployLimit, or 0
+}
+
+// Cast fires a spell at a lane. Cell counts from the caster's own towers
+// (1 is the closest cell) and is 0 for spells that cover the whole lane.
+type Cast struct {
+	Spell string // Spell id (F, Z, E, A)
+	Lane  string // Lane id from the layout, e.g. "L"
+	Cell  int    // Target cell, 1 to the lane length, or 0
+}
+
+func (Deploy) isCommand() {}
+func (Cast) isCommand()   {}
+
+func (d Deploy) String() string {
+	if d.Cell == 0 {
+		return d.Troop + "-" + d.Lane
+	}
+	return fmt.Sprintf("%s-%s-%d", d.Troop, d.Lane, d.Cell)
+}
+
+func (c Cast) String() string {
+	if c.Cell == 0 {
+		return c.Spell + "-" + c.Lane
+	}
+	return fmt.Sprintf("%s-%s-%d", c.Spell, c.Lane, c.Cell)
+}
+
+// Input is a command issued by one of the two players
+type Input struct {
+	Player  int
+	Command Command
+}
+
+// ParseCommand turns a player's text command such as "K-L", "K-L-3" or "F-C-3" into a
+// typed Command, checking card ids and lanes against the match's catalog and
+// layout and the card against the player's hand
+func ParseCommand(s *State, player int, text string) (Command, error) {
+	catalog := s.Catalog
+	length := s.Layout.LaneLength
+
+	text = strings.ToUpper(strings.TrimSpace(text))
+	parts := strings.Split(text, "-")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, ErrMalformed
+	}
+
+	id := parts[0]
+	lane := parts[1]
+
+	// Validate card id
+	spell := catalog.Spell(id)
+	if catalog.Troop(id) == nil && spell == nil {
+		return nil, invalidCard(catalog)
+	}
+	if !s.Player(player).InHand(id) {
+		return nil, notInHand(s, player, id)
+	}
+
+	// Validate lane
+	if s.Layout.Lane(lane) == nil {
+		return nil, invalidLane(s)
+	}
+
+	// Validate target cell
+	cell := 0
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 1 || n > length {
+			return nil, invalidCell(s)
+		}
+		cell = n
+	}
+
+	if spell != nil {
+		if spell.TargetsCell() && cell == 0 {
+			return nil, needsCell(spell, lane)
+		}
+		if !spell.TargetsCell() {
+			cell = 0
+		}
+		return Cast{Spell: id, Lane: lane, Cell: cell}, nil
+	}
+	if cell > s.DeployLimit(player, lane) {
+		return nil, deployTooFar(s, player, lane)
+	}
+	return Deploy{Troop: id, Lane: lane, Cell: cell}, nil
+}
+
+// checkCommand repeats ParseCommand's checks on a typed command, which may
+// have been built without it. Only the hand and the deploy limit are left to
+// deploy and cast, as they can change between parsing and applying.
+func checkCommand(s *State, cmd Command) error {
+	switch cmd := cmd.(type) {
+	case Deploy:
+		if s.Catalog.Troop(cmd.Troop) == nil {
+			return invalidCard(s.Catalog)
+		}
+		if s.Layout.Lane(cmd.Lane) == nil {
+			return invalidLane(s)
+		}
+		if cmd.Cell < 0 || cmd.Cell > s.Layout.LaneLength {
+			return invalidCell(s)
+		}
+	case Cast:
+		spell := s.Catalog.Spell(cmd.Spell)
+		if spell == nil {
+			return invalidCard(s.Catalog)
+		}
+		if s.Layout.Lane(cmd.Lane) == nil {
+			return invalidLane(s)
+		}
+		if cmd.Cell < 0 || cmd.Cell > s.Layout.LaneLength {
+			return invalidCell(s)
+		}
+		if spell.TargetsCell() && cmd.Cell == 0 {
+			return needsCell(spell, cmd.Lane)
+		}
+	default:
+		return ErrMalformed
+	}
+	return nil
+}
+
+// invalidCard builds the error for a card id the catalog does not have
+func invalidCard(c *Catalog) error {
+	return fmt.Errorf("Invalid card! Use troops %s or spells %s.",
+		strings.Join(c.TroopIDs(), ", "), strings.Join(c.SpellIDs(), ", "))
+}
+
+// invalidLane builds the error for a lane id the layout does not have
+func invalidLane(s *State) error {
+	return fmt.Errorf("Invalid lane! Use %s.", strings.Join(s.Layout.LaneIDs(), ", "))
+}
+
+// invalidCell builds the error for a cell outside the lane
+func invalidCell(s *State) error {
+	return fmt.Errorf("Invalid cell! Use 1 to %d, counted from your towers.", s.Layout.LaneLength)
+}
+
+// needsCell builds the error for a cell spell cast without a target
+func needsCell(spell *SpellDef, lane string) error {
+	return fmt.Errorf("%s needs a target cell, e.g. %s-%s-3.", spell.Name, spell.ID, lane)
+}
+
+// deployTooFar builds the error for a deployment beyond the player's DeployLimit
+func deployTooFar(s *State, player int, lane string) error {
+	return fmt.Errorf("You can only deploy up to cell %d in the %s lane until its enemy tower falls.",
+		s.DeployLimit(player, lane), s.Layout.Lane(lane).Name)
+}
+
+// apply executes a single input against the state. Inputs from neither
+// player are ignored, and invalid commands are rejected.
+func apply(s *State, in Input) {
+	if in.Player != 1 && in.Player != 2 {
+		return
+	}
+	if err := checkCommand(s, in.Command); err != nil {
+		s.emit(CommandRejected{Player: in.Player, Reason: err.Error()})
+		return
+	}
+	switch cmd := in.Command.(type) {
+	case Deploy:
+		deploy(s, in.Player, cmd)
+	case Cast:
+		cast(s, in.Player, cmd)
+	}
+}
+
+// deploy spends mana and spawns a level-scaled troop
+func deploy(s *State, player int, cmd Deploy) {
+	p := s.Player(player)
+
+	// Check mana cost
+	base := s.Catalog.Troop(cmd.Troop)
+	if !s.checkHand(player, cmd.Troop) || !s.canAfford(player, base.Mana) {
+		return
+	}
+	cell := max(cmd.Cell, 1)
+	if cell > s.DeployLimit(player, cmd.Lane) {
+		s.emit(CommandRejected{Player: player, Reason: deployTooFar(s, player, cmd.Lane).Error()})
+		return
+	}
+	p.Mana -= float64(base.Mana)
+	p.playCard(cmd.Troop)
+
+	spawnTroop(s, player, cmd.Troop, cmd.Lane, s.LaneCell(player, cell))
+	s.emit(TroopDeployed{Player: player, Troop: cmd.Troop, Lane: cmd.Lane, Cell: cell})
+}
+
+// spawnTroop creates a troop with level-scaled stats and runs its deploy hooks
+func spawnTroop(s *State, player int, troopType, lane string, cell int) *Troop {
+	base := s.Catalog.Troop(troopType)
+	level := s.Player(player).Level
+	hp := ScaleStat(base.HP, level)
+
+	s.NextID++
+	t := &Troop{
+		ID:       s.NextID,
+		Player:   player,
+		Type:     troopType,
+		Lane:     lane,
+		Position: cell,
+		Age:      0,
+		Alive:    true,
+		HP:       hp,
+		MaxHP:    hp,
+		Atk:      ScaleStat(base.Atk, level),
+		Def:      ScaleStat(base.Def, level),
+	}
+	s.Troops = append(s.Troops, t)
+	onDeploy(s, t)
+	return t
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	s.Player(1).Hand = []string{"K", "B", "F", "A"}
+
+	valid := map[string]Command{
+		"K-L":   Deploy{Troop: "K", Lane: "L"},
+		"k-c-3": Deploy{Troop: "K", Lane: "C", Cell: 3},
+		" B-R ": Deploy{Troop: "B", Lane: "R"},
+		"F-C-5": Cast{Spell: "F", Lane: "C", Cell: 5},
+		"A-R":   Cast{Spell: "A", Lane: "R"},
+		"A-R-2": Cast{Spell: "A", Lane: "R"}, // Lane spells ignore the cell
+	}
+	for text, want := range valid {
+		got, err := ParseCommand(s, 1, text)
+		if err != nil {
+			t.Errorf("ParseCommand(%q): %v", text, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseCommand(%q) = %#v, want %#v", text, got, want)
+		}
+	}
+}
+
+func TestParseCommandRejects(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	s.Player(1).Hand = []string{"K", "B", "F", "A"}
+
+	rejected := map[string]string{
+		"P-L":   "not in your hand",
+		"Z-L":   "not in your hand",
+		"X-L":   "Invalid card",
+		"K-X":   "Invalid lane",
+		"K-L-0": "Invalid cell",
+		"K-L-6": "Invalid cell",
+		"K-L-4": "only deploy up to cell 3",
+		"F-C":   "needs a target cell",
+	}
+	for text, want := range rejected {
+		_, err := ParseCommand(s, 1, text)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCommand(%q) error = %v, want one containing %q", text, err, want)
+		}
+	}
+	for _, text := range []string{"", "K", "K-L-3-1"} {
+		if _, err := ParseCommand(s, 1, text); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseCommand(%q) error = %v, want ErrMalformed", text, err)
+		}
+	}
+}
+
+func TestDeployLimit(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	s.Player(1).Hand = []string{"K", "B", "F", "A"}
+
+	if got := s.DeployLimit(1, "L"); got != 3 {
+		t.Fatalf("DeployLimit on a 5-cell lane = %d, want 3", got)
+	}
+	// The whole lane opens up once the enemy tower guarding it falls
+	s.Towers[2]["L"].HP = 0
+	if got := s.DeployLimit(1, "L"); got != 5 {
+		t.Fatalf("DeployLimit with the enemy tower down = %d, want 5", got)
+	}
+	if _, err := ParseCommand(s, 1, "K-L-5"); err != nil {
+		t.Errorf("ParseCommand(K-L-5) with the enemy tower down: %v", err)
+	}
+	if _, err := ParseCommand(s, 1, "K-R-5"); err == nil {
+		t.Errorf("ParseCommand(K-R-5) accepted a deploy beyond a standing tower")
+	}
+}
+
+func TestStepRejectsInvalidInputs(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	s.Player(1).Hand = []string{"K", "B", "F", "A"}
+	s.Player(1).Mana = 10
+
+	rejected := []Input{
+		{Player: 1, Command: Deploy{Troop: "K", Lane: "X"}},
+		{Player: 1, Command: Deploy{Troop: "F", Lane: "L"}},
+		{Player: 1, Command: Deploy{Troop: "K", Lane: "L", Cell: -1}},
+		{Player: 1, Command: Cast{Spell: "F", Lane: "X", Cell: 3}},
+		{Player: 1, Command: Cast{Spell: "F", Lane: "L"}},
+		{Player: 1, Command: Cast{Spell: "F", Lane: "L", Cell: 6}},
+		{Player: 1, Command: Cast{Spell: "K", Lane: "L"}},
+		{Player: 1},
+	}
+	for _, in := range rejected {
+		_, events := Step(s, []Input{in})
+		if len(events) == 0 {
+			t.Fatalf("%#v was not rejected", in)
+		}
+		if _, ok := events[0].(CommandRejected); !ok {
+			t.Fatalf("%#v produced %#v, want CommandRejected", in, events[0])
+		}
+	}
+
+	// Inputs from neither player are dropped
+	for _, player := range []int{0, 3, -1} {
+		if _, events := Step(s, []Input{{Player: player, Command: Deploy{Troop: "K", Lane: "L"}}}); len(events) != 0 {
+			t.Fatalf("input from player %d produced %v", player, events)
+		}
+	}
+	if s.Player(1).Mana < 10 || len(s.Troops) != 0 {
+		t.Fatal("a rejected input changed the match")
+	}
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	DeckSize = 8 // Cards a player brings to a match
+	HandSize = 4 // Cards a player can choose from at any time
+)
+
+// ValidateDeck checks that a deck has DeckSize different cards from the catalog
+func (c *Catalog) ValidateDeck(deck []string) error {
+	if len(deck) != DeckSize {
+		return fmt.Errorf("a deck needs exactly %d cards", DeckSize)
+	}
+	for i, card := range deck {
+		if c.Troop(card) == nil && c.Spell(card) == nil {
+			return fmt.Errorf("unknown card %q", card)
+		}
+		if slices.Contains(deck[:i], card) {
+			return fmt.Errorf("card %s is in the deck twice", card)
+		}
+	}
+	return nil
+}
+
+// DeckOrDefault returns the deck if it is valid, or the catalog's default deck
+func (c *Catalog) DeckOrDefault(deck []string) []string {
+	if c.ValidateDeck(deck) != nil {
+		return c.DefaultDeck
+	}
+	return deck
+}
+
+// CardName returns the display name of a troop or spell card
+func (c *Catalog) CardName(id string) string {
+	if t := c.Troop(id); t != nil {
+		return t.Name
+	}
+	if sp := c.Spell(id); sp != nil {
+		return sp.Name
+	}
+	return id
+}
+
+// CardMana returns the mana cost of a troop or spell card
+func (c *Catalog) CardMana(id string) int {
+	if t := c.Troop(id); t != nil {
+		return t.Mana
+	}
+	if sp := c.Spell(id); sp != nil {
+		return sp.Mana
+	}
+	return 0
+}
+
+// dealHand shuffles a deck and deals the opening hand
+func (s *State) dealHand(player int, deck []string) {
+	cards := slices.Clone(deck)
+	s.rng.Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+	p := s.Player(player)
+	p.Hand = cards[:HandSize:HandSize]
+	p.Queue = cards[HandSize:]
+}
+
+// InHand reports whether a card can be played right now
+func (p *PlayerState) InHand(card string) bool {
+	return slices.Contains(p.Hand, card)
+}
+
+// Next returns the card that replaces the next card played
+func (p *PlayerState) Next() string {
+	if len(p.Queue) == 0 {
+		return ""
+	}
+	return p.Queue[0]
+}
+
+// playCard puts the next card into the played card's hand slot and moves
+// the played card to the back of the deck
+func (p *PlayerState) playCard(card string) {
+	i := slices.Index(p.Hand, card)
+	if i < 0 || len(p.Queue) == 0 {
+		return
+	}
+	p.Hand[i] = p.Queue[0]
+	p.Queue = append(p.Queue[1:], card)
+}
+
+// checkHand reports a rejection if a card is not in the player's hand
+func (s *State) checkHand(player int, card string) bool {
+	if s.Player(player).InHand(card) {
+		return true
+	}
+	s.emit(CommandRejected{Player: player, Reason: notInHand(s, player, card).Error()})
+	return false
+}
+
+// notInHand builds the error for playing a card that is not in hand
+func notInHand(s *State, player int, card string) error {
+	return fmt.Errorf("%s is not in your hand! Hand: %s.",
+		s.Catalog.CardName(card), strings.Join(s.Player(player).Hand, ", "))
+}
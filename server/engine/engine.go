@@ -0,0 +1,238 @@
+// Package engine implements the Text Clash Royale game rules without any
+// networking. A match is a State that is advanced one tick at a time by Step,
+// which consumes typed commands and reports what happened as events.
+package engine
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Game timing
+const (
+	TickRate     = 10                     // Simulation steps per second
+	TickDuration = time.Second / TickRate // Simulation time covered by one Step
+)
+
+// Rules are the match settings that can differ between rooms
+type Rules struct {
+	MatchLength      time.Duration `json:"match_length"`       // Regulation time
+	Overtime         time.Duration `json:"overtime"`           // Sudden-death time added after a tied regulation; 0 skips it
+	ManaCap          float64       `json:"mana_cap"`           // Mana stops regenerating at this value
+	ManaPerSecond    float64       `json:"mana_per_second"`    // Base mana regeneration
+	DoubleManaTime   time.Duration `json:"double_mana_time"`   // Final part of regulation with double regeneration
+	OvertimeManaRate float64       `json:"overtime_mana_rate"` // Regeneration multiplier during overtime
+	TripleMana       bool          `json:"triple_mana"`        // Event mode: base regeneration is tripled all match
+	CrownsToWin      int           `json:"crowns_to_win"`      // A player with this many crowns wins at once; 0 disables the rule
+	Scaling          Scaling       `json:"scaling"`            // How player levels scale unit stats
+}
+
+// DefaultRules returns the standard match settings
+func DefaultRules() Rules {
+	return Rules{
+		MatchLength:      3 * time.Minute,
+		Overtime:         1 * time.Minute,
+		ManaCap:          10,
+		ManaPerSecond:    0.5,
+		DoubleManaTime:   1 * time.Minute,
+		OvertimeManaRate: 2,
+	}
+}
+
+// Troop represents a unit deployed on the map
+type Troop struct {
+	ID       int     // Unique within the match, in deployment order
+	Player   int     // Player ID (1 or 2)
+	Type     string  // Type of troop (P, B, R, K, I, Q)
+	Lane     string  // Lane id from the layout, e.g. "L"
+	Position int     // Cell on the lane, 0 on player 1's side (see lane.go)
+	Age      int     // How many ticks the troop has been alive
+	Progress float64 // Distance covered towards the next cell
+	Cooldown int     // Ticks until the troop can attack again
+	Target   int     // ID of the enemy troop being fought, 0 if none
+	Alive    bool    // Is the troop still active?
+	HP       int     // Current HP
+	MaxHP    int     // HP at deployment
+	Shield   int     // Damage absorbed before HP is lost
+	Walked   int     // Cells walked since the troop last attacked
+	Atk      int     // Attack power
+	Def      int     // Defense
+}
+
+// Tower represents a defensive structure
+type Tower struct {
+	Kind     string // Catalog tower id ("King" or "Guard")
+	HP       int
+	MaxHP    int
+	Atk      int
+	Def      int
+	Crit     float64
+	Cooldown int  // Ticks until the tower can shoot again
+	Active   bool // Whether the tower shoots at all (the King starts asleep)
+}
+
+// PlayerState holds the per-player values the rules care about
+type PlayerState struct {
+	Level int      // Level used for stat scaling, after the Scaling policy
+	Mana  float64  // Current mana, regenerating in fractions of a point
+	Hand  []string // Cards that can be played right now
+	Queue []string // Rest of the deck in cycle order; Queue[0] is the next card
+}
+
+// State is the complete state of one match
+type State struct {
+	Tick     int                       // Number of Steps taken so far
+	Players  [2]PlayerState            // Player 1 and Player 2
+	Troops   []*Troop                  // All active troops, in deployment order
+	NextID   int                       // ID given to the most recently deployed troop
+	Effects  []*Effect                 // Spells still acting on a lane
+	Towers   map[int]map[string]*Tower // Towers by player ID and lane
+	Rules    Rules                     // Settings this match is played with
+	Overtime bool                      // Regulation ended tied and sudden death is on
+	Crowns   [2]int                    // Crowns each player had when overtime began
+	Phase    string                    // Mana phase the last Step ended in
+	Winner   int                       // 0 for draw or while the match is running
+	Reason   string                    // Rule that decided the match ("" while running)
+	Seed     uint64                    // Seed of the match RNG
+	Catalog  *Catalog                  `json:"-"` // Troop and tower definitions for this match
+	Layout   *Layout                   `json:"-"` // Arena the match is played on
+
+	pcg    *rand.PCG  // Generator behind rng, kept so snapshots can save its position
+	rng    *rand.Rand // Source of all randomness in the simulation
+	events []Event    // Events produced by the Step in progress
+}
+
+// Config describes how a match is set up
+type Config struct {
+	Catalog *Catalog    // Troop, spell and tower definitions
+	Layout  *Layout     // Arena to play on; nil picks the catalog's first layout
+	Seed    uint64      // Seed of the match RNG
+	Levels  [2]int      // Levels of player 1 and player 2
+	Decks   [2][]string // Decks of player 1 and player 2; invalid or empty decks use the catalog's default deck
+	Rules   Rules       // Match settings; the zero value means DefaultRules
+}
+
+// NewState creates a fresh match from a Config. The seed fully determines
+// every random roll, so two states created from the same Config and fed the
+// same inputs play out identically.
+func NewState(cfg Config) *State {
+	layout := cfg.Layout
+	if layout == nil {
+		layout = &cfg.Catalog.Layouts[0]
+	}
+
+	rules := cfg.Rules
+	if rules == (Rules{}) {
+		rules = DefaultRules()
+	}
+
+	pcg := rand.NewPCG(cfg.Seed, cfg.Seed)
+	s := &State{
+		Rules:   rules,
+		Catalog: cfg.Catalog,
+		Layout:  layout,
+		Players: [2]PlayerState{{Level: rules.Scaling.Apply(cfg.Levels[0])}, {Level: rules.Scaling.Apply(cfg.Levels[1])}},
+		Troops:  []*Troop{},
+		Towers:  make(map[int]map[string]*Tower),
+		Phase:   PhaseNormal,
+		Seed:    cfg.Seed,
+		pcg:     pcg,
+		rng:     rand.New(pcg),
+	}
+
+	// Every lane gets the tower the layout assigns to it
+	for playerNum := 1; playerNum <= 2; playerNum++ {
+		level := s.Player(playerNum).Level
+		s.Towers[playerNum] = make(map[string]*Tower)
+		for _, lane := range layout.Lanes {
+			s.Towers[playerNum][lane.ID] = newTower(cfg.Catalog.Tower(lane.Tower), level)
+		}
+	}
+
+	// Decks are shuffled by the match RNG, so the seed also fixes the draw order
+	for playerNum := 1; playerNum <= 2; playerNum++ {
+		s.dealHand(playerNum, cfg.Catalog.DeckOrDefault(cfg.Decks[playerNum-1]))
+	}
+	return s
+}
+
+// newTower builds a tower from its definition with level-scaled stats
+func newTower(def *TowerDef, level int) *Tower {
+	hp := ScaleStat(def.HP, level)
+	return &Tower{
+		Kind:   def.ID,
+		HP:     hp,
+		MaxHP:  hp,
+		Atk:    ScaleStat(def.Atk, level),
+		Def:    ScaleStat(def.Def, level),
+		Crit:   def.Crit,
+		Active: def.ID != "King",
+	}
+}
+
+// ScaleStat applies the 10%-per-level bonus to a base stat
+func ScaleStat(base, level int) int {
+	return int(float64(base) * (1.0 + 0.1*float64(level)))
+}
+
+// Player returns the state of player 1 or 2
+func (s *State) Player(id int) *PlayerState {
+	return &s.Players[id-1]
+}
+
+// Over reports whether the match has ended
+func (s *State) Over() bool {
+	return s.Reason != ""
+}
+
+// Elapsed returns the match time simulated so far
+func (s *State) Elapsed() time.Duration {
+	return time.Duration(s.Tick) * TickDuration
+}
+
+// TimeLeft returns the time until regulation ends, or until overtime ends
+// once it has begun
+func (s *State) TimeLeft() time.Duration {
+	end := s.Rules.MatchLength
+	if s.Overtime {
+		end += s.Rules.Overtime
+	}
+	return max(end-s.Elapsed(), 0)
+}
+
+// Step applies the inputs received since the previous tick, then advances the
+// match by one tick. Inputs always take effect at this tick boundary, in the
+// order given, whatever the real time they arrived at. The state is updated
+// in place and returned together with the events produced along the way.
+// Stepping a finished match does nothing.
+func Step(s *State, inputs []Input) (*State, []Event) {
+	s.events = nil
+	if s.Over() {
+		return s, nil
+	}
+
+	for _, in := range inputs {
+		apply(s, in)
+	}
+
+	s.Tick++
+	expireEffects(s)
+	regenMana(s)
+
+	updateTroops(s)
+	applyCombat(s)
+	checkGameOver(s)
+	if phase := s.ManaPhase(); phase != s.Phase && !s.Over() {
+		s.Phase = phase
+		s.emit(ManaPhaseChanged{Phase: phase, Multiplier: s.ManaMultiplier()})
+	}
+
+	events := s.events
+	s.events = nil
+	return s, events
+}
+
+// emit records an event for the Step in progress
+func (s *State) emit(ev Event) {
+	s.events = append(s.events, ev)
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+// testLayouts are the arenas of the catalog the server ships with
+var testLayouts = []string{"classic", "long", "duel", "arena"}
+
+// maxTestTicks stops a match that fails to end on its own
+const maxTestTicks = 10000
+
+// testCatalog loads the catalog the server ships with
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := LoadCatalog("../catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// newTestState starts a match on a layout with both players at level 1
+func newTestState(t *testing.T, layout string, seed uint64) *State {
+	t.Helper()
+	c := testCatalog(t)
+	if c.Layout(layout) == nil {
+		t.Fatalf("catalog has no layout %q", layout)
+	}
+	return NewState(Config{Catalog: c, Layout: c.Layout(layout), Seed: seed, Levels: [2]int{1, 1}})
+}
+
+// randomInputs picks what the players send before a tick: now and then a
+// random card from the hand, aimed at a random lane and cell. Commands the
+// player cannot afford are left in; the rules must reject them the same way
+// every time.
+func randomInputs(s *State, rng *rand.Rand) []Input {
+	var inputs []Input
+	lanes := s.Layout.LaneIDs()
+	for player := 1; player <= 2; player++ {
+		hand := s.Player(player).Hand
+		if rng.IntN(10) != 0 || len(hand) == 0 {
+			continue
+		}
+		lane := lanes[rng.IntN(len(lanes))]
+		text := fmt.Sprintf("%s-%s-%d", hand[rng.IntN(len(hand))], lane, 1+rng.IntN(s.DeployLimit(player, lane)))
+		if cmd, err := ParseCommand(s, player, text); err == nil {
+			inputs = append(inputs, Input{Player: player, Command: cmd})
+		}
+	}
+	return inputs
+}
+
+// stepRandom steps a match with randomInputs until it is over or has reached
+// tick, recording the inputs in r unless it is nil
+func stepRandom(t *testing.T, s *State, rng *rand.Rand, r *Replay, tick int) {
+	t.Helper()
+	for !s.Over() && s.Tick < tick {
+		inputs := randomInputs(s, rng)
+		if r != nil {
+			r.Record(s, inputs)
+		}
+		Step(s, inputs)
+	}
+	if tick >= maxTestTicks && !s.Over() {
+		t.Fatalf("match still running after %d ticks", s.Tick)
+	}
+}
+
+// sameMatch fails the test unless two states are at the same point of the
+// same match
+func sameMatch(t *testing.T, got, want *State) {
+	t.Helper()
+	if got.Tick != want.Tick || got.Winner != want.Winner || got.Reason != want.Reason {
+		t.Fatalf("match at tick %d won by %d (%q), want tick %d won by %d (%q)",
+			got.Tick, got.Winner, got.Reason, want.Tick, want.Winner, want.Reason)
+	}
+	if !reflect.DeepEqual(got.Towers, want.Towers) {
+		t.Fatalf("towers differ at tick %d", got.Tick)
+	}
+	if !reflect.DeepEqual(got.Troops, want.Troops) {
+		t.Fatalf("troops differ at tick %d", got.Tick)
+	}
+	if !reflect.DeepEqual(got.Players, want.Players) {
+		t.Fatalf("players differ at tick %d", got.Tick)
+	}
+}
+
+func TestSameSeedSameMatch(t *testing.T) {
+	for _, layout := range testLayouts {
+		t.Run(layout, func(t *testing.T) {
+			a := newTestState(t, layout, 7)
+			b := newTestState(t, layout, 7)
+			rng := rand.New(rand.NewPCG(1, 2))
+			for !a.Over() {
+				if a.Tick >= maxTestTicks {
+					t.Fatalf("match still running after %d ticks", a.Tick)
+				}
+				inputs := randomInputs(a, rng)
+				_, ea := Step(a, inputs)
+				_, eb := Step(b, inputs)
+				if !reflect.DeepEqual(ea, eb) {
+					t.Fatalf("events differ at tick %d:\n%v\n%v", a.Tick, ea, eb)
+				}
+			}
+			sameMatch(t, b, a)
+		})
+	}
+}
+
+func TestSeedChangesMatch(t *testing.T) {
+	a := newTestState(t, "classic", 7)
+	b := newTestState(t, "classic", 8)
+	if reflect.DeepEqual(a.Players, b.Players) {
+		t.Fatal("different seeds dealt the same cards")
+	}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import "fmt"
+
+// Event describes something that happened during a Step
+type Event interface {
+	isEvent()
+}
+
+// CommandRejected is reported when a command cannot be carried out
+type CommandRejected struct {
+	Player int
+	Reason string
+}
+
+// TroopDeployed is reported when a troop enters the map. Cell is counted
+// from the owner's towers.
+type TroopDeployed struct {
+	Player int
+	Troop  string
+	Lane   string
+	Cell   int
+}
+
+// SpellCast is reported when a spell is played. Cell is counted from the
+// caster's towers, or 0 for spells that cover the whole lane.
+type SpellCast struct {
+	Player int
+	Spell  string
+	Lane   string
+	Cell   int
+}
+
+// Healed is reported when a troop ability restores tower HP
+type Healed struct {
+	Player int    // Owner of the healed tower
+	Lane   string // Lane of the healed tower
+	Type   string // Troop type of the healer
+	Amount int
+}
+
+// OvertimeStarted is reported when regulation ends level and sudden death begins
+type OvertimeStarted struct{}
+
+// TroopAttacked is reported whenever a troop takes damage
+type TroopAttacked struct {
+	Player   int    // Owner of the troop that was hit
+	Troop    int    // ID of the troop that was hit
+	Type     string // Troop type of the troop that was hit
+	Lane     string
+	Attacker int // ID of the troop that struck, or 0 for towers and spells
+	Damage   int
+}
+
+// TroopDied is reported when a troop is brought to 0 HP
+type TroopDied struct {
+	Player int // Owner of the troop that died
+	Troop  int
+	Type   string
+	Lane   string
+	Killer int // ID of the troop that landed the last blow, or 0 for towers and spells
+}
+
+// TowerDamaged is reported whenever a tower loses HP
+type TowerDamaged struct {
+	Player int // Owner of the tower
+	Lane   string
+	Damage int
+	HP     int // HP left, never below 0
+}
+
+// TowerDestroyed is reported when a tower falls
+type TowerDestroyed struct {
+	Player int // Owner of the tower
+	Lane   string
+	Kind   string
+}
+
+// CriticalHit is reported when a tower's shot is critical
+type CriticalHit struct {
+	Player int // Owner of the tower
+	Lane   string
+	Damage int
+}
+
+// ManaPhaseChanged is reported when mana starts regenerating at a new rate
+type ManaPhaseChanged struct {
+	Phase      string  // PhaseNormal, PhaseDouble or PhaseOvertime
+	Multiplier float64 // Regeneration relative to Rules.ManaPerSecond
+}
+
+func (CommandRejected) isEvent()  {}
+func (OvertimeStarted) isEvent()  {}
+func (TroopDeployed) isEvent()    {}
+func (SpellCast) isEvent()        {}
+func (Healed) isEvent()           {}
+func (TroopAttacked) isEvent()    {}
+func (TroopDied) isEvent()        {}
+func (TowerDamaged) isEvent()     {}
+func (TowerDestroyed) isEvent()   {}
+func (CriticalHit) isEvent()      {}
+func (ManaPhaseChanged) isEvent() {}
+
+// Describe turns an event into a line of text for players, logs and
+// transcripts. Frequent events (TroopAttacked, TowerDamaged) return "".
+func (s *State) Describe(ev Event) string {
+	laneName := func(id string) string {
+		if lane := s.Layout.Lane(id); lane != nil {
+			return lane.Name
+		}
+		return id
+	}
+
+	switch ev := ev.(type) {
+	case CommandRejected:
+		return ev.Reason
+	case TroopDeployed:
+		return fmt.Sprintf("Player %d deployed %s to %s lane at cell %d",
+			ev.Player, s.Catalog.CardName(ev.Troop), ev.Lane, ev.Cell)
+	case SpellCast:
+		msg := fmt.Sprintf("Player %d cast %s on %s lane", ev.Player, s.Catalog.CardName(ev.Spell), ev.Lane)
+		if ev.Cell != 0 {
+			msg += fmt.Sprintf(" at cell %d from P%d's towers", ev.Cell, ev.Player)
+		}
+		return msg + "!"
+	case Healed:
+		return fmt.Sprintf("%s healed Player %d's %s tower by %d HP!", s.Catalog.CardName(ev.Type), ev.Player, ev.Lane, ev.Amount)
+	case TroopDied:
+		return fmt.Sprintf("Player %d's %s fell in %s lane", ev.Player, s.Catalog.CardName(ev.Type), ev.Lane)
+	case TowerDestroyed:
+		if ev.Kind == "King" {
+			return fmt.Sprintf("Player %d's King tower destroyed!", ev.Player)
+		}
+		return fmt.Sprintf("Player %d's %s tower destroyed!", ev.Player, laneName(ev.Lane))
+	case CriticalHit:
+		return fmt.Sprintf("Player %d's %s tower landed a critical hit for %d!", ev.Player, laneName(ev.Lane), ev.Damage)
+	case ManaPhaseChanged:
+		return fmt.Sprintf("Mana phase: %s, regenerating x%g", ev.Phase, ev.Multiplier)
+	case OvertimeStarted:
+		return "=== OVERTIME! Mana flows faster and the first tower destroyed wins! ==="
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// castRecentEvents is how many of the latest events each asciicast frame lists
+// under the map
+const castRecentEvents = 5
+
+// Result returns how the replayed match ended: as simulated when the playback
+// finished it, otherwise as recorded (e.g. when a player disconnected)
+func (r *Replay) Result(s *State) (winner int, reason string) {
+	if s.Over() {
+		return s.Winner, s.Reason
+	}
+	return r.Winner, r.Reason
+}
+
+// resultText describes a replay's result in a sentence
+func (r *Replay) resultText(s *State) string {
+	winner, reason := r.Result(s)
+	if winner == 0 {
+		return fmt.Sprintf("Draw, %d-%d crowns (%s)", Crowns(s, 1), Crowns(s, 2), ReasonText[reason])
+	}
+	return fmt.Sprintf("Player %d (%s) wins, %d-%d crowns (%s)",
+		winner, r.Players[winner-1], Crowns(s, 1), Crowns(s, 2), ReasonText[reason])
+}
+
+// clock formats a game time as mm:ss
+func clock(d time.Duration) string {
+	secs := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+}
+
+// timeline re-simulates a replay and calls line with the game time and the
+// description of every event worth showing, then with the result
+func (r *Replay) timeline(step func(s *State), line func(at time.Duration, text string)) *State {
+	s := r.Play(func(s *State, events []Event) {
+		for _, ev := range events {
+			if _, ok := ev.(CommandRejected); ok {
+				continue // Rejected commands were only ever shown to their sender
+			}
+			if text := s.Describe(ev); text != "" {
+				line(s.Elapsed(), text)
+			}
+		}
+		if step != nil {
+			step(s)
+		}
+	})
+	line(s.Elapsed(), r.resultText(s))
+	return s
+}
+
+// WriteTranscript writes a replay as a readable timeline, one event per line,
+// e.g. "00:42 Player 1 deployed Knight to L lane at cell 1"
+func WriteTranscript(w io.Writer, r *Replay) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s (P1) vs %s (P2) on %s, seed %d, catalog %.12s\n\n",
+		r.Players[0], r.Players[1], r.Layout, r.Seed, r.CatalogHash)
+	r.timeline(nil, func(at time.Duration, text string) {
+		fmt.Fprintf(bw, "%s %s\n", clock(at), text)
+	})
+	return bw.Flush()
+}
+
+// WriteAsciicast writes a replay as an asciicast v2 terminal recording, with
+// one screen per second of game time showing the map from viewer's side and
+// the latest events. Recording time is game time.
+func WriteAsciicast(w io.Writer, r *Replay, viewer int) error {
+	type frame struct {
+		at   time.Duration
+		text string
+	}
+	var frames []frame
+	var recent []string
+	width, height := 0, 0
+
+	// screen draws the current state and remembers the largest screen so far,
+	// which sizes the recording's terminal
+	screen := func(s *State) {
+		lines := []string{fmt.Sprintf("%s (P1) vs %s (P2)   %s   Crowns: %d-%d",
+			r.Players[0], r.Players[1], clock(s.Elapsed()), Crowns(s, 1), Crowns(s, 2)), ""}
+		lines = append(lines, strings.Split(strings.TrimRight(RenderMap(s, viewer), "\n"), "\n")...)
+		lines = append(lines, "")
+		lines = append(lines, recent...)
+		for _, l := range lines {
+			width = max(width, len(l))
+		}
+		height = max(height, len(lines))
+		// Clear the screen and home the cursor, then draw with terminal line endings
+		frames = append(frames, frame{s.Elapsed(), "\x1b[2J\x1b[H" + strings.Join(lines, "\r\n")})
+	}
+
+	s := r.timeline(func(s *State) {
+		if s.Tick%TickRate == 0 {
+			screen(s)
+		}
+	}, func(at time.Duration, text string) {
+		recent = append(recent, clock(at)+" "+text)
+		if len(recent) > castRecentEvents {
+			recent = recent[1:]
+		}
+	})
+	screen(s) // The final screen includes the result
+
+	bw := bufio.NewWriter(w)
+	header, err := json.Marshal(map[string]any{
+		"version": 2,
+		"width":   width,
+		"height":  height,
+		"title":   fmt.Sprintf("%s vs %s", r.Players[0], r.Players[1]),
+	})
+	if err != nil {
+		return err
+	}
+	bw.Write(append(header, '\n'))
+	for _, f := range frames {
+		line, err := json.Marshal([]any{f.at.Seconds(), "o", f.text})
+		if err != nil {
+			return err
+		}
+		bw.Write(append(line, '\n'))
+	}
+	return bw.Flush()
+}
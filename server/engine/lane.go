@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lanes use a single coordinate system for both players. Cells are numbered
+// from 0 on player 1's side to LaneLength-1 on player 2's side; player 1's
+// towers stand just before cell 0 and player 2's towers just after the last
+// cell. Player 1's troops walk towards higher cells, player 2's towards lower.
+
+// Layout describes an arena: how long its lanes are and which tower guards
+// each lane. Both players get the same towers on their own side.
+type Layout struct {
+	Name       string    `json:"name"`
+	LaneLength int       `json:"lane_length"` // Cells in every lane
+	Lanes      []LaneDef `json:"lanes"`       // Lanes from top to bottom of the map
+}
+
+// LaneDef is one lane of a layout
+type LaneDef struct {
+	ID    string `json:"id"`    // Lane letter used in commands, e.g. "L"
+	Name  string `json:"name"`  // Display name, e.g. "Left"
+	Tower string `json:"tower"` // Catalog tower kind guarding the lane
+}
+
+// Layout limits
+const (
+	MinLanes      = 2
+	MaxLanes      = 5
+	MinLaneLength = 3
+)
+
+// validate checks a layout against the catalog's towers
+func (l *Layout) validate(c *Catalog) error {
+	switch {
+	case l.Name == "":
+		return fmt.Errorf("layout without a name")
+	case len(l.Lanes) < MinLanes || len(l.Lanes) > MaxLanes:
+		return fmt.Errorf("layout %s: needs %d to %d lanes", l.Name, MinLanes, MaxLanes)
+	case l.LaneLength < MinLaneLength:
+		return fmt.Errorf("layout %s: lanes need at least %d cells", l.Name, MinLaneLength)
+	}
+
+	kings := 0
+	seen := make(map[string]bool)
+	for i := range l.Lanes {
+		lane := &l.Lanes[i]
+		lane.ID = strings.ToUpper(lane.ID)
+		switch {
+		case lane.ID == "" || strings.ContainsAny(lane.ID, "- "):
+			return fmt.Errorf("layout %s: invalid lane id %q", l.Name, lane.ID)
+		case seen[lane.ID]:
+			return fmt.Errorf("layout %s: duplicate lane %s", l.Name, lane.ID)
+		case c.Tower(lane.Tower) == nil:
+			return fmt.Errorf("layout %s: lane %s has unknown tower %q", l.Name, lane.ID, lane.Tower)
+		}
+		if lane.Name == "" {
+			lane.Name = lane.ID
+		}
+		if lane.Tower == "King" {
+			kings++
+		}
+		seen[lane.ID] = true
+	}
+	if kings != 1 {
+		return fmt.Errorf("layout %s: needs exactly one King lane", l.Name)
+	}
+	return nil
+}
+
+// Lane returns the lane with the given id, or nil if the layout has none
+func (l *Layout) Lane(id string) *LaneDef {
+	for i := range l.Lanes {
+		if l.Lanes[i].ID == id {
+			return &l.Lanes[i]
+		}
+	}
+	return nil
+}
+
+// LaneIDs returns the lane ids from top to bottom
+func (l *Layout) LaneIDs() []string {
+	ids := make([]string, len(l.Lanes))
+	for i, lane := range l.Lanes {
+		ids[i] = lane.ID
+	}
+	return ids
+}
+
+// KingLane returns the id of the lane guarded by the King tower
+func (l *Layout) KingLane() string {
+	for _, lane := range l.Lanes {
+		if lane.Tower == "King" {
+			return lane.ID
+		}
+	}
+	return ""
+}
+
+// forward returns the direction a player's troops move in (+1 or -1)
+func forward(player int) int {
+	if player == 1 {
+		return 1
+	}
+	return -1
+}
+
+// startCell returns the cell where a player's troops enter a lane
+func (s *State) startCell(player int) int {
+	if player == 1 {
+		return 0
+	}
+	return s.Layout.LaneLength - 1
+}
+
+// towerCell returns the coordinate of a player's towers, one step outside the lane
+func (s *State) towerCell(player int) int {
+	if player == 1 {
+		return -1
+	}
+	return s.Layout.LaneLength
+}
+
+// LaneCell converts a cell counted from a player's own towers (1 is the
+// closest) to a lane coordinate
+func (s *State) LaneCell(player, n int) int {
+	if player == 1 {
+		return n - 1
+	}
+	return s.Layout.LaneLength - n
+}
+
+// distance returns the number of cells between two coordinates
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// atEnemyTower reports whether a troop stands on the last cell before the enemy towers
+func (s *State) atEnemyTower(t *Troop) bool {
+	return t.Position+forward(t.Player) == s.towerCell(3-t.Player)
+}
+
+// reachedTower reports whether a troop has reached the enemy towers: it is
+// within range of the tower standing in its lane, or next to the towers
+func (s *State) reachedTower(t *Troop) bool {
+	enemy := 3 - t.Player
+	tower := s.Towers[enemy][t.Lane]
+	if tower.HP > 0 && distance(t.Position, s.towerCell(enemy)) <= s.Catalog.Tower(tower.Kind).Range {
+		return true
+	}
+	return s.atEnemyTower(t)
+}
+
+// DeployLimit returns the furthest cell, counted from the player's own
+// towers, where the player may deploy in a lane. That is their own half
+// (including the middle cell of an odd lane) or the whole lane once the
+// enemy tower guarding it has fallen.
+func (s *State) DeployLimit(player int, lane string) int {
+	if s.Towers[3-player][lane].HP <= 0 {
+		return s.Layout.LaneLength
+	}
+	return (s.Layout.LaneLength + 1) / 2
+}
+
+// TroopsAt returns the living troops in a cell, in deployment order
+func (s *State) TroopsAt(lane string, cell int) []*Troop {
+	var troops []*Troop
+	for _, t := range s.Troops {
+		if t.Alive && t.Lane == lane && t.Position == cell {
+			troops = append(troops, t)
+		}
+	}
+	return troops
+}
+
+// fallbackLane picks where a troop goes once the tower of its lane has
+// fallen: the nearest lane whose guard tower still stands, or the King's
+// lane when none do
+func (s *State) fallbackLane(player int, from string) string {
+	enemy := 3 - player
+	index := 0
+	for i, lane := range s.Layout.Lanes {
+		if lane.ID == from {
+			index = i
+		}
+	}
+
+	best := ""
+	bestDist := 0
+	for i, lane := range s.Layout.Lanes {
+		if lane.ID == from || lane.Tower == "King" || s.Towers[enemy][lane.ID].HP <= 0 {
+			continue
+		}
+		if d := distance(i, index); best == "" || d < bestDist {
+			best, bestDist = lane.ID, d
+		}
+	}
+	if best == "" {
+		return s.Layout.KingLane()
+	}
+	return best
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Mana phases, from slowest to fastest regeneration
+const (
+	PhaseNormal   = "normal"
+	PhaseDouble   = "double"   // Final DoubleManaTime of regulation
+	PhaseOvertime = "overtime" // Sudden death
+)
+
+// ManaPhase returns the current regeneration phase of the match
+func (s *State) ManaPhase() string {
+	switch {
+	case s.Overtime:
+		return PhaseOvertime
+	case s.Rules.DoubleManaTime > 0 && s.Elapsed() >= s.Rules.MatchLength-s.Rules.DoubleManaTime:
+		return PhaseDouble
+	default:
+		return PhaseNormal
+	}
+}
+
+// ManaMultiplier returns how much faster than the base rate mana currently
+// regenerates, including the triple-mana event bonus
+func (s *State) ManaMultiplier() float64 {
+	mult := 1.0
+	if s.Rules.TripleMana {
+		mult = 3
+	}
+	switch s.ManaPhase() {
+	case PhaseDouble:
+		mult *= 2
+	case PhaseOvertime:
+		mult *= s.Rules.OvertimeManaRate
+	}
+	return mult
+}
+
+// ManaRate returns the current mana regeneration per second
+func (s *State) ManaRate() float64 {
+	return s.Rules.ManaPerSecond * s.ManaMultiplier()
+}
+
+// NextManaIn returns how long until a player's mana reaches its next whole
+// point at the current rate, or 0 when the player's mana is full
+func (s *State) NextManaIn(player int) time.Duration {
+	mana := s.Player(player).Mana
+	rate := s.ManaRate()
+	if mana >= s.Rules.ManaCap || rate <= 0 {
+		return 0
+	}
+	missing := math.Floor(mana) + 1 - mana
+	return time.Duration(missing / rate * float64(time.Second))
+}
+
+// regenMana adds one tick of regeneration to both players. Mana is kept to
+// three decimals so that many small steps add up to whole points exactly.
+func regenMana(s *State) {
+	regen := s.ManaRate() * TickDuration.Seconds()
+	for i := range s.Players {
+		mana := math.Round((s.Players[i].Mana+regen)*1000) / 1000
+		s.Players[i].Mana = min(mana, s.Rules.ManaCap)
+	}
+}
+
+// canAfford checks a cost against a player's mana and reports a rejection if
+// the player is short
+func (s *State) canAfford(player, cost int) bool {
+	if s.Player(player).Mana >= float64(cost) {
+		return true
+	}
+	s.emit(CommandRejected{
+		Player: player,
+		Reason: fmt.Sprintf("Not enough mana (need %d)!", cost),
+	})
+	return false
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderMap draws the arena as seen by a player: their own towers on the
+// left and cells counted from the left, so K-L-3 lands in the third column
+func RenderMap(s *State, viewer int) string {
+	length := s.Layout.LaneLength
+	enemy := 3 - viewer
+
+	// A cell shows its earliest troop, plus how many more troops share it
+	lanes := map[string][]string{}
+	for _, lane := range s.Layout.Lanes {
+		cells := make([]string, length)
+		for i := range cells {
+			cells[i] = " "
+			troops := s.TroopsAt(lane.ID, s.LaneCell(viewer, i+1))
+			if len(troops) == 0 {
+				continue
+			}
+			symbol := fmt.Sprintf("%s%d", troops[0].Type, troops[0].Player)
+			if len(troops) > 1 {
+				symbol += fmt.Sprintf("+%d", len(troops)-1)
+			}
+			cells[i] = symbol
+		}
+		lanes[lane.ID] = cells
+	}
+
+	formatHP := func(hp int) string {
+		if hp < 5 {
+			return "X"
+		}
+		return fmt.Sprintf("%d", hp)
+	}
+
+	// Lanes under a Freeze or Rage are tagged at the end of their row
+	effects := map[string]string{}
+	for _, e := range s.Effects {
+		effects[e.Lane] += fmt.Sprintf("  {%s by P%d}", strings.ToUpper(e.Kind[:1])+e.Kind[1:], e.Player)
+	}
+
+	lineSep := "                          " + strings.TrimSpace(strings.Repeat("--- ", length))
+
+	var rows []string
+	for _, lane := range s.Layout.Lanes {
+		cells := "| " + strings.Join(lanes[lane.ID], " | ") + " |"
+		myHP := formatHP(s.Towers[viewer][lane.ID].HP)
+		enemyHP := formatHP(s.Towers[enemy][lane.ID].HP)
+		if lane.Tower == "King" {
+			rows = append(rows, fmt.Sprintf("[P%d KingTower - %s] => %s <= [P%d KingTower - %s]%s",
+				viewer, myHP, cells, enemy, enemyHP, effects[lane.ID]))
+		} else {
+			rows = append(rows, fmt.Sprintf("[P%d Tower%s - %s]  ===>  %s  <===  [P%d Tower%s - %s]%s",
+				viewer, lane.ID, myHP, cells, enemy, lane.ID, enemyHP, effects[lane.ID]))
+		}
+	}
+
+	mapStr := fmt.Sprintf(`+---------------------- TEXT CLASH ROYALE MAP ----------------------+
+
+%s
+
++------------------------------------------------------------------+`,
+		strings.Join(rows, "\n"+lineSep+"\n"))
+
+	return mapStr
+}
@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Version identifies the rules implementation. It is stored in replays and
+// must change whenever the same inputs could produce a different match.
+const Version = "1.2"
+
+// Replay is everything needed to play a match again: the setup it started
+// from and every command with the tick it was applied on
+type Replay struct {
+	Version     string          `json:"version"`
+	CatalogHash string          `json:"catalog_hash"`
+	Catalog     json.RawMessage `json:"catalog"` // The full catalog, so old replays survive balance changes
+	Layout      string          `json:"layout"`
+	Rules       Rules           `json:"rules"`
+	Seed        uint64          `json:"seed"`
+	Levels      [2]int          `json:"levels"`
+	Decks       [2][]string     `json:"decks"`
+	Players     [2]string       `json:"players"` // Display names, for viewers only
+	Commands    []RecordedInput `json:"commands"`
+	Ticks       int             `json:"ticks"`  // Tick the match ended on
+	Winner      int             `json:"winner"` // Result as it was played
+	Reason      string          `json:"reason"`
+	catalog     *Catalog
+}
+
+// RecordedInput is a command and the tick it was applied on. Tick is the
+// value State.Tick had when the command was passed to Step.
+type RecordedInput struct {
+	Tick    int    `json:"tick"`
+	Player  int    `json:"player"`
+	Command string `json:"command"`
+}
+
+// NewReplay starts a recording of a match set up from cfg
+func NewReplay(cfg Config, players [2]string) *Replay {
+	layout := cfg.Layout
+	if layout == nil {
+		layout = &cfg.Catalog.Layouts[0]
+	}
+	return &Replay{
+		Version:     Version,
+		CatalogHash: cfg.Catalog.Hash(),
+		Catalog:     cfg.Catalog.JSON(),
+		Layout:      layout.Name,
+		Rules:       cfg.Rules,
+		Seed:        cfg.Seed,
+		Levels:      cfg.Levels,
+		Decks:       [2][]string{cfg.Catalog.DeckOrDefault(cfg.Decks[0]), cfg.Catalog.DeckOrDefault(cfg.Decks[1])},
+		Players:     players,
+		catalog:     cfg.Catalog,
+	}
+}
+
+// Record adds the inputs about to be passed to Step for state s
+func (r *Replay) Record(s *State, inputs []Input) {
+	for _, in := range inputs {
+		r.Commands = append(r.Commands, RecordedInput{Tick: s.Tick, Player: in.Player, Command: in.Command.String()})
+	}
+}
+
+// Finish stores how the match ended
+func (r *Replay) Finish(s *State, winner int, reason string) {
+	r.Ticks = s.Tick
+	r.Winner = winner
+	r.Reason = reason
+}
+
+// Finished reports whether Finish has been called
+func (r *Replay) Finished() bool {
+	return r.Reason != ""
+}
+
+// Save writes the replay to a file
+func (r *Replay) Save(path string) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadReplay reads a replay file and checks that it can be played back
+func LoadReplay(path string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseReplay(data)
+}
+
+// ParseReplay decodes a replay and checks its version and catalog
+func ParseReplay(data []byte) (*Replay, error) {
+	var r Replay
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.Version != Version {
+		return nil, fmt.Errorf("replay was recorded with engine version %s, this is %s", r.Version, Version)
+	}
+	c, err := ParseCatalog(r.Catalog)
+	if err != nil {
+		return nil, fmt.Errorf("replay catalog: %v", err)
+	}
+	if c.Hash() != r.CatalogHash {
+		return nil, fmt.Errorf("replay catalog does not match its hash")
+	}
+	if c.Layout(r.Layout) == nil {
+		return nil, fmt.Errorf("replay layout %q is not in its catalog", r.Layout)
+	}
+	r.catalog = c
+	return &r, nil
+}
+
+// Config returns the setup the recorded match started from
+func (r *Replay) Config() Config {
+	return Config{
+		Catalog: r.catalog,
+		Layout:  r.catalog.Layout(r.Layout),
+		Seed:    r.Seed,
+		Levels:  r.Levels,
+		Decks:   r.Decks,
+		Rules:   r.Rules,
+	}
+}
+
+// Playback steps through a recorded match one tick at a time
+type Playback struct {
+	Replay *Replay
+	State  *State
+	next   int // Index of the first command not yet applied
+}
+
+// NewPlayback starts a playback at the beginning of the match
+func (r *Replay) NewPlayback() *Playback {
+	return &Playback{Replay: r, State: NewState(r.Config())}
+}
+
+// Done reports whether the playback has reached the end of the recording
+func (p *Playback) Done() bool {
+	return p.State.Over() || p.State.Tick >= p.Replay.Ticks
+}
+
+// Step advances the playback by one tick, applying the commands recorded for
+// it, and returns the events of that tick
+func (p *Playback) Step() []Event {
+	if p.Done() {
+		return nil
+	}
+	s := p.State
+	var inputs []Input
+	for ; p.next < len(p.Replay.Commands) && p.Replay.Commands[p.next].Tick == s.Tick; p.next++ {
+		rec := p.Replay.Commands[p.next]
+		cmd, err := ParseCommand(s, rec.Player, rec.Command)
+		if err != nil {
+			continue // Recorded commands always parsed when they were played
+		}
+		inputs = append(inputs, Input{Player: rec.Player, Command: cmd})
+	}
+	_, events := Step(s, inputs)
+	return events
+}
+
+// Seek moves the playback to a tick. The simulation cannot run backwards, so
+// seeking back replays the match from the start.
+func (p *Playback) Seek(tick int) {
+	if tick < p.State.Tick {
+		*p = *p.Replay.NewPlayback()
+	}
+	for p.State.Tick < tick && !p.Done() {
+		p.Step()
+	}
+}
+
+// Play re-simulates the whole match, calling step after every tick with the
+// state and the events of that tick, and returns the final state
+func (r *Replay) Play(step func(s *State, events []Event)) *State {
+	p := r.NewPlayback()
+	for !p.Done() {
+		events := p.Step()
+		if step != nil {
+			step(p.State, events)
+		}
+	}
+	return p.State
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayRoundTrip(t *testing.T) {
+	for i, layout := range testLayouts {
+		t.Run(layout, func(t *testing.T) {
+			c := testCatalog(t)
+			cfg := Config{
+				Catalog: c,
+				Layout:  c.Layout(layout),
+				Seed:    uint64(100 + i),
+				Levels:  [2]int{2, 5},
+				Decks:   [2][]string{nil, {"P", "B", "R", "K", "I", "G", "Z", "E"}},
+			}
+			s := NewState(cfg)
+			r := NewReplay(cfg, [2]string{"a", "b"})
+			stepRandom(t, s, rand.New(rand.NewPCG(uint64(i), 3)), r, maxTestTicks)
+			r.Finish(s, s.Winner, s.Reason)
+
+			path := filepath.Join(t.TempDir(), "match.json")
+			if err := r.Save(path); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadReplay(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(loaded.Commands) == 0 {
+				t.Fatal("replay recorded no commands")
+			}
+
+			end := loaded.Play(nil)
+			sameMatch(t, end, s)
+			if end.Tick != loaded.Ticks || end.Winner != loaded.Winner || end.Reason != loaded.Reason {
+				t.Fatalf("replay ended at tick %d won by %d (%q), recorded tick %d won by %d (%q)",
+					end.Tick, end.Winner, end.Reason, loaded.Ticks, loaded.Winner, loaded.Reason)
+			}
+		})
+	}
+}
+
+func TestPlaybackSeek(t *testing.T) {
+	c := testCatalog(t)
+	cfg := Config{Catalog: c, Seed: 5, Levels: [2]int{1, 1}}
+	s := NewState(cfg)
+	r := NewReplay(cfg, [2]string{"a", "b"})
+	stepRandom(t, s, rand.New(rand.NewPCG(5, 5)), r, maxTestTicks)
+	r.Finish(s, s.Winner, s.Reason)
+
+	// Seeking back starts over, so it must land on the same state as playing forward
+	want := r.NewPlayback()
+	want.Seek(600)
+	p := r.NewPlayback()
+	p.Seek(1200)
+	p.Seek(600)
+	sameMatch(t, p.State, want.State)
+}
+
+func TestParseReplayRejectsChangedCatalog(t *testing.T) {
+	c := testCatalog(t)
+	cfg := Config{Catalog: c, Seed: 1}
+	r := NewReplay(cfg, [2]string{"a", "b"})
+	r.CatalogHash = "0"
+	path := filepath.Join(t.TempDir(), "match.json")
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadReplay(path); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Fatalf("LoadReplay error = %v, want a catalog hash mismatch", err)
+	}
+}
@@ -0,0 +1,131 @@
+package engine
+
+// Reasons a match can end with, reported in State.Reason
+const (
+	ReasonKingTower   = "king_tower"   // A King tower was destroyed
+	ReasonCrowns      = "crowns"       // A player reached Rules.CrownsToWin
+	ReasonTimeUp      = "time_up"      // Regulation ended with one side ahead on crowns
+	ReasonSuddenDeath = "sudden_death" // First tower destroyed in overtime
+	ReasonTowerHP     = "tower_hp"     // Tie-breaker on remaining tower HP
+	ReasonDraw        = "draw"         // Still level after every tie-breaker
+	ReasonDisconnect  = "disconnect"   // A player left; set by the server, never by Step
+)
+
+// ReasonText describes each reason, for showing with a result
+var ReasonText = map[string]string{
+	ReasonKingTower:   "King tower destroyed",
+	ReasonCrowns:      "reached the crown target",
+	ReasonTimeUp:      "more towers destroyed when time ran out",
+	ReasonSuddenDeath: "first tower destroyed in overtime",
+	ReasonTowerHP:     "tie-breaker: more tower HP left",
+	ReasonDraw:        "level on towers and tower HP",
+	ReasonDisconnect:  "opponent disconnected",
+}
+
+// checkGameOver decides whether the match has ended. A destroyed King tower
+// ends it at once, as does reaching the crown target when one is set. When
+// regulation time runs out the player with more crowns wins; if that is level
+// the match goes to overtime, where the first crown wins. If overtime also
+// ends level, the player with the larger share of tower HP left wins, and
+// only then is it a draw.
+func checkGameOver(s *State) {
+	king := s.Layout.KingLane()
+	if s.Towers[1][king].HP <= 0 {
+		s.Winner, s.Reason = 2, ReasonKingTower
+		return
+	}
+	if s.Towers[2][king].HP <= 0 {
+		s.Winner, s.Reason = 1, ReasonKingTower
+		return
+	}
+
+	crowns1, crowns2 := Crowns(s, 1), Crowns(s, 2)
+	if target := s.Rules.CrownsToWin; target > 0 && max(crowns1, crowns2) >= target {
+		switch {
+		case crowns1 > crowns2:
+			s.Winner, s.Reason = 1, ReasonCrowns
+			return
+		case crowns2 > crowns1:
+			s.Winner, s.Reason = 2, ReasonCrowns
+			return
+		}
+	}
+
+	if s.Overtime {
+		// Sudden death: whoever has taken more crowns since overtime began wins
+		switch new1, new2 := crowns1-s.Crowns[0], crowns2-s.Crowns[1]; {
+		case new1 > new2:
+			s.Winner, s.Reason = 1, ReasonSuddenDeath
+			return
+		case new2 > new1:
+			s.Winner, s.Reason = 2, ReasonSuddenDeath
+			return
+		}
+		if s.Elapsed() >= s.Rules.MatchLength+s.Rules.Overtime {
+			tieBreak(s)
+		}
+		return
+	}
+
+	if s.Elapsed() < s.Rules.MatchLength {
+		return
+	}
+	switch {
+	case crowns1 > crowns2:
+		s.Winner, s.Reason = 1, ReasonTimeUp
+	case crowns2 > crowns1:
+		s.Winner, s.Reason = 2, ReasonTimeUp
+	case s.Rules.Overtime > 0:
+		s.Overtime = true
+		s.Crowns = [2]int{crowns1, crowns2}
+		s.emit(OvertimeStarted{})
+	default:
+		tieBreak(s)
+	}
+}
+
+// tieBreak settles a level match on the share of tower HP each player kept
+func tieBreak(s *State) {
+	hp1, hp2 := TowerHPShare(s, 1), TowerHPShare(s, 2)
+	switch {
+	case hp1 > hp2:
+		s.Winner, s.Reason = 1, ReasonTowerHP
+	case hp2 > hp1:
+		s.Winner, s.Reason = 2, ReasonTowerHP
+	default:
+		s.Winner, s.Reason = 0, ReasonDraw
+	}
+}
+
+// Crowns counts the crowns a player has taken: one for every enemy guard
+// tower destroyed, and three in total once the enemy King tower falls (more
+// only on layouts with over three guard towers, if all of them fell too).
+func Crowns(s *State, player int) int {
+	n := 0
+	king := false
+	for _, tower := range s.Towers[3-player] {
+		if tower.HP > 0 {
+			continue
+		}
+		if tower.Kind == "King" {
+			king = true
+		} else {
+			n++
+		}
+	}
+	if king {
+		n = max(n, 3)
+	}
+	return n
+}
+
+// TowerHPShare returns the fraction of a player's total tower HP still
+// standing, between 0 and 1. Healing above the maximum is not counted.
+func TowerHPShare(s *State, player int) float64 {
+	left, total := 0, 0
+	for _, tower := range s.Towers[player] {
+		left += max(0, min(tower.HP, tower.MaxHP))
+		total += tower.MaxHP
+	}
+	return float64(left) / float64(total)
+}
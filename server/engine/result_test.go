@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+// newShortMatch starts a classic match with one second of regulation and the
+// given overtime, so tests reach the end of the clock quickly
+func newShortMatch(t *testing.T, overtime time.Duration) *State {
+	t.Helper()
+	c := testCatalog(t)
+	rules := DefaultRules()
+	rules.MatchLength = time.Second
+	rules.Overtime = overtime
+	return NewState(Config{Catalog: c, Layout: c.Layout("classic"), Seed: 1, Levels: [2]int{1, 1}, Rules: rules})
+}
+
+// stepUntil steps a match without inputs until done reports true or the
+// match ends, and returns every event produced on the way
+func stepUntil(t *testing.T, s *State, done func() bool) []Event {
+	t.Helper()
+	var all []Event
+	for !done() && !s.Over() {
+		if s.Tick >= maxTestTicks {
+			t.Fatalf("still waiting after %d ticks", s.Tick)
+		}
+		_, events := Step(s, nil)
+		all = append(all, events...)
+	}
+	return all
+}
+
+func TestOvertimeSuddenDeath(t *testing.T) {
+	s := newShortMatch(t, time.Second)
+	// A crown each when time runs out is still level
+	s.Towers[1]["L"].HP = 0
+	s.Towers[2]["R"].HP = 0
+
+	events := stepUntil(t, s, func() bool { return s.Overtime })
+	if s.Over() {
+		t.Fatalf("level match ended at time up, won by %d (%q)", s.Winner, s.Reason)
+	}
+	started := false
+	for _, ev := range events {
+		if _, ok := ev.(OvertimeStarted); ok {
+			started = true
+		}
+	}
+	if !started {
+		t.Fatal("overtime began without an OvertimeStarted event")
+	}
+	if got := s.ManaMultiplier(); got != s.Rules.OvertimeManaRate {
+		t.Fatalf("mana regenerates %vx in overtime, want %vx", got, s.Rules.OvertimeManaRate)
+	}
+
+	// The first tower to fall in overtime decides the match
+	s.Towers[1]["R"].HP = 0
+	Step(s, nil)
+	if s.Winner != 2 || s.Reason != ReasonSuddenDeath {
+		t.Fatalf("match won by %d (%q), want 2 on sudden death", s.Winner, s.Reason)
+	}
+}
+
+func TestTimeUpWithCrownLead(t *testing.T) {
+	s := newShortMatch(t, time.Second)
+	s.Towers[2]["L"].HP = 0
+
+	stepUntil(t, s, func() bool { return false })
+	if s.Overtime || s.Winner != 1 || s.Reason != ReasonTimeUp {
+		t.Fatalf("match won by %d (%q), overtime %v; want 1 on time up", s.Winner, s.Reason, s.Overtime)
+	}
+}
+
+func TestTowerHPTieBreak(t *testing.T) {
+	for _, overtime := range []time.Duration{time.Second, 0} {
+		t.Run(overtime.String(), func(t *testing.T) {
+			s := newShortMatch(t, overtime)
+			// Level on crowns, but player 1 has taken more tower HP
+			s.Towers[2]["L"].HP -= 400
+			s.Towers[1]["C"].HP -= 100
+
+			stepUntil(t, s, func() bool { return false })
+			if s.Winner != 1 || s.Reason != ReasonTowerHP {
+				t.Fatalf("match won by %d (%q), want 1 on tower HP", s.Winner, s.Reason)
+			}
+			if want := s.Rules.MatchLength + overtime; s.Elapsed() != want {
+				t.Fatalf("match ended after %v, want %v", s.Elapsed(), want)
+			}
+		})
+	}
+}
+
+func TestDrawAfterTieBreak(t *testing.T) {
+	s := newShortMatch(t, time.Second)
+	s.Towers[1]["L"].HP -= 100
+	s.Towers[2]["R"].HP -= 100
+
+	stepUntil(t, s, func() bool { return false })
+	if s.Winner != 0 || s.Reason != ReasonDraw {
+		t.Fatalf("match won by %d (%q), want a draw", s.Winner, s.Reason)
+	}
+}
+
+func TestTowerHPShareIgnoresOverheal(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	s.Towers[1]["L"].HP += 500
+	if got := TowerHPShare(s, 1); got != 1 {
+		t.Fatalf("TowerHPShare with a healed tower = %v, want 1", got)
+	}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Level scaling policies
+const (
+	ScalingFull     = "full"     // Every level adds 10% to every stat
+	ScalingCapped   = "capped"   // Levels above Scaling.Level count as Scaling.Level
+	ScalingStandard = "standard" // Every unit plays at Scaling.Level, whatever the player's level
+)
+
+// Scaling is the policy that turns a player's level into the level their
+// troops and towers are scaled by. The zero value is full scaling.
+type Scaling struct {
+	Mode  string `json:"mode"`  // ScalingFull, ScalingCapped or ScalingStandard; "" means full
+	Level int    `json:"level"` // Cap or standard level
+}
+
+// Apply returns the level a player's units are scaled by
+func (sc Scaling) Apply(level int) int {
+	switch sc.Mode {
+	case ScalingCapped:
+		return min(level, sc.Level)
+	case ScalingStandard:
+		return sc.Level
+	default:
+		return level
+	}
+}
+
+// String describes the policy for players
+func (sc Scaling) String() string {
+	switch sc.Mode {
+	case ScalingCapped:
+		return fmt.Sprintf("levels capped at %d", sc.Level)
+	case ScalingStandard:
+		return fmt.Sprintf("tournament standard, every unit at level %d", sc.Level)
+	default:
+		return "full level scaling"
+	}
+}
+
+// ParseScaling reads a policy written as "full", "capped <level>" or
+// "standard <level>"
+func ParseScaling(text string) (Scaling, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) == 1 && fields[0] == ScalingFull {
+		return Scaling{Mode: ScalingFull}, nil
+	}
+	if len(fields) != 2 || (fields[0] != ScalingCapped && fields[0] != ScalingStandard) {
+		return Scaling{}, fmt.Errorf("use full, capped <level> or standard <level>")
+	}
+	level, err := strconv.Atoi(fields[1])
+	if err != nil || level < 1 {
+		return Scaling{}, fmt.Errorf("level must be a number of at least 1")
+	}
+	return Scaling{Mode: fields[0], Level: level}, nil
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+)
+
+// Snapshot is a match saved part-way through: every field of its State,
+// the position of its RNG and the catalog it is played with. A State restored
+// from a snapshot plays on exactly as the original would have.
+type Snapshot struct {
+	Version     string          `json:"version"`
+	CatalogHash string          `json:"catalog_hash"`
+	Catalog     json.RawMessage `json:"catalog"`
+	Layout      string          `json:"layout"`
+	RNG         []byte          `json:"rng"` // PCG state, from MarshalBinary
+	State       *State          `json:"state"`
+}
+
+// Snapshot captures the state between two Steps. The snapshot refers to s
+// itself, so encode it before s is stepped again.
+func (s *State) Snapshot() (*Snapshot, error) {
+	rng, err := s.pcg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		Version:     Version,
+		CatalogHash: s.Catalog.Hash(),
+		Catalog:     s.Catalog.JSON(),
+		Layout:      s.Layout.Name,
+		RNG:         rng,
+		State:       s,
+	}, nil
+}
+
+// Restore rebuilds the State a snapshot was taken from
+func (snap *Snapshot) Restore() (*State, error) {
+	if snap.Version != Version {
+		return nil, fmt.Errorf("snapshot was taken with engine version %s, this is %s", snap.Version, Version)
+	}
+	if snap.State == nil {
+		return nil, fmt.Errorf("snapshot has no state")
+	}
+	c, err := ParseCatalog(snap.Catalog)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot catalog: %v", err)
+	}
+	if c.Hash() != snap.CatalogHash {
+		return nil, fmt.Errorf("snapshot catalog does not match its hash")
+	}
+	layout := c.Layout(snap.Layout)
+	if layout == nil {
+		return nil, fmt.Errorf("snapshot layout %q is not in its catalog", snap.Layout)
+	}
+	pcg := &rand.PCG{}
+	if err := pcg.UnmarshalBinary(snap.RNG); err != nil {
+		return nil, fmt.Errorf("snapshot RNG: %v", err)
+	}
+
+	s := *snap.State
+	s.Catalog = c
+	s.Layout = layout
+	s.pcg = pcg
+	s.rng = rand.New(pcg)
+	if s.Troops == nil {
+		s.Troops = []*Troop{}
+	}
+	return &s, nil
+}
+
+// Clone returns a deep copy of the state, RNG included, that can be stepped
+// without touching the original. Catalog and layout are shared; neither is
+// changed by Step.
+func (s *State) Clone() *State {
+	c := *s
+	for i := range c.Players {
+		c.Players[i].Hand = append([]string(nil), s.Players[i].Hand...)
+		c.Players[i].Queue = append([]string(nil), s.Players[i].Queue...)
+	}
+	c.Troops = make([]*Troop, len(s.Troops))
+	for i, t := range s.Troops {
+		troop := *t
+		c.Troops[i] = &troop
+	}
+	c.Effects = nil // Most ticks have no spell effects running
+	if s.Effects != nil {
+		c.Effects = make([]*Effect, len(s.Effects))
+	}
+	for i, e := range s.Effects {
+		effect := *e
+		c.Effects[i] = &effect
+	}
+	c.Towers = make(map[int]map[string]*Tower, len(s.Towers))
+	for player, lanes := range s.Towers {
+		c.Towers[player] = make(map[string]*Tower, len(lanes))
+		for lane, t := range lanes {
+			tower := *t
+			c.Towers[player][lane] = &tower
+		}
+	}
+	pcg := *s.pcg
+	c.pcg = &pcg
+	c.rng = rand.New(c.pcg)
+	c.events = nil
+	return &c
+}
+
+// Reseed gives the state a new source for its random rolls. A clone that is
+// played ahead, e.g. by a bot, should be reseeded so it cannot foresee the
+// rolls of the match it was copied from. Seed keeps the match's seed.
+func (s *State) Reseed(seed uint64) {
+	s.pcg = rand.NewPCG(seed, seed)
+	s.rng = rand.New(s.pcg)
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRestoreContinues(t *testing.T) {
+	for i, layout := range testLayouts {
+		t.Run(layout, func(t *testing.T) {
+			s := newTestState(t, layout, uint64(20+i))
+			rng := rand.New(rand.NewPCG(uint64(i), 9))
+			stepRandom(t, s, rng, nil, 900)
+
+			// Go through JSON like a snapshot file does
+			snap, err := s.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(snap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var loaded Snapshot
+			if err := json.Unmarshal(data, &loaded); err != nil {
+				t.Fatal(err)
+			}
+			restored, err := loaded.Restore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameMatch(t, restored, s)
+
+			for !s.Over() {
+				if s.Tick >= maxTestTicks {
+					t.Fatalf("match still running after %d ticks", s.Tick)
+				}
+				inputs := randomInputs(s, rng)
+				_, want := Step(s, inputs)
+				_, got := Step(restored, inputs)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("events differ at tick %d:\n%v\n%v", s.Tick, got, want)
+				}
+			}
+			sameMatch(t, restored, s)
+		})
+	}
+}
+
+func TestRestoreRejectsChangedCatalog(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	snap, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap.CatalogHash = "0"
+	if _, err := snap.Restore(); err == nil {
+		t.Fatal("snapshot with a wrong catalog hash was restored")
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+// Effect is a spell that keeps acting on a lane for a while
+type Effect struct {
+	Kind   string // "freeze" or "rage"
+	Player int    // Player who cast the spell
+	Lane   string
+	Boost  int // Rage bonus in percent
+	Until  int // Tick at which the effect wears off
+}
+
+// cast spends mana and applies a spell. Spell damage ignores defense.
+// Fireball and Arrows hit at once;
+// Freeze and Rage leave an Effect on the lane.
+func cast(s *State, player int, cmd Cast) {
+	p := s.Player(player)
+
+	// Check mana cost
+	spell := s.Catalog.Spell(cmd.Spell)
+	if !s.checkHand(player, cmd.Spell) || !s.canAfford(player, spell.Mana) {
+		return
+	}
+	p.Mana -= float64(spell.Mana)
+	p.playCard(cmd.Spell)
+
+	s.emit(SpellCast{Player: player, Spell: cmd.Spell, Lane: cmd.Lane, Cell: cmd.Cell})
+
+	enemy := 3 - player
+	switch spell.Effect {
+	case "fireball":
+		center := s.LaneCell(player, cmd.Cell)
+		for _, t := range s.Troops {
+			if t.Alive && t.Player == enemy && t.Lane == cmd.Lane && distance(t.Position, center) <= spell.Radius {
+				damageTroop(s, t, spell.Damage, 0)
+			}
+		}
+		if distance(s.towerCell(enemy), center) <= spell.Radius {
+			damageTower(s, enemy, cmd.Lane, spell.Damage)
+		}
+
+	case "arrows":
+		for _, t := range s.Troops {
+			if t.Alive && t.Player == enemy && t.Lane == cmd.Lane {
+				damageTroop(s, t, spell.Damage, 0)
+			}
+		}
+
+	case "freeze", "rage":
+		s.Effects = append(s.Effects, &Effect{
+			Kind:   spell.Effect,
+			Player: player,
+			Lane:   cmd.Lane,
+			Boost:  spell.Boost,
+			Until:  s.Tick + secondsToTicks(spell.Duration),
+		})
+	}
+}
+
+// expireEffects drops lane effects whose time is up
+func expireEffects(s *State) {
+	active := s.Effects[:0]
+	for _, e := range s.Effects {
+		if s.Tick < e.Until {
+			active = append(active, e)
+		}
+	}
+	s.Effects = active
+}
+
+// frozen reports whether an enemy Freeze holds the troop in place
+func frozen(s *State, t *Troop) bool {
+	for _, e := range s.Effects {
+		if e.Kind == "freeze" && e.Lane == t.Lane && e.Player != t.Player {
+			return true
+		}
+	}
+	return false
+}
+
+// rageBoost returns the strongest friendly Rage bonus on the troop's lane in percent
+func rageBoost(s *State, t *Troop) int {
+	boost := 0
+	for _, e := range s.Effects {
+		if e.Kind == "rage" && e.Lane == t.Lane && e.Player == t.Player && e.Boost > boost {
+			boost = e.Boost
+		}
+	}
+	return boost
+}
+
+// boosted applies a percentage bonus to a value
+func boosted(v float64, percent int) float64 {
+	return v * (1 + float64(percent)/100)
+}
@@ -0,0 +1,139 @@
+package engine
+
+import "testing"
+
+// castSpell gives a player the spell and the mana for it, then casts it
+func castSpell(t *testing.T, s *State, player int, cmd Cast) {
+	t.Helper()
+	p := s.Player(player)
+	p.Hand = []string{cmd.Spell}
+	p.Mana = s.Rules.ManaCap
+	cast(s, player, cmd)
+	if p.Mana == s.Rules.ManaCap {
+		t.Fatalf("%#v was not cast", cmd)
+	}
+}
+
+func TestFireballArea(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	fireball := s.Catalog.Spell("F")
+	center := s.LaneCell(1, 4)
+
+	// Golems cannot hurt each other, so nothing but the spell deals damage
+	hit := []*Troop{
+		spawnTroop(s, 2, "G", "L", center-fireball.Radius),
+		spawnTroop(s, 2, "G", "L", center),
+		spawnTroop(s, 2, "G", "L", center+fireball.Radius),
+	}
+	missed := []*Troop{
+		spawnTroop(s, 2, "G", "L", center+fireball.Radius+1),
+		spawnTroop(s, 2, "G", "R", center), // Other lane
+		spawnTroop(s, 1, "G", "L", center), // Own troop
+	}
+	castSpell(t, s, 1, Cast{Spell: "F", Lane: "L", Cell: 4})
+	for _, g := range hit {
+		if g.HP != g.MaxHP-fireball.Damage {
+			t.Errorf("troop at %d has %d of %d HP, want %d damage", g.Position, g.HP, g.MaxHP, fireball.Damage)
+		}
+	}
+	for _, g := range missed {
+		if g.HP != g.MaxHP {
+			t.Errorf("player %d's troop in lane %s at %d was hit", g.Player, g.Lane, g.Position)
+		}
+	}
+	if s.Towers[2]["L"].HP != s.Towers[2]["L"].MaxHP {
+		t.Error("fireball far from the tower damaged it")
+	}
+
+	// Next to the enemy towers the blast reaches the tower too
+	castSpell(t, s, 1, Cast{Spell: "F", Lane: "L", Cell: s.Layout.LaneLength})
+	if tower := s.Towers[2]["L"]; tower.HP != tower.MaxHP-fireball.Damage {
+		t.Errorf("tower has %d of %d HP, want %d damage", tower.HP, tower.MaxHP, fireball.Damage)
+	}
+}
+
+func TestArrowsHitWholeLane(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	arrows := s.Catalog.Spell("A")
+	near := spawnTroop(s, 2, "G", "R", 0)
+	far := spawnTroop(s, 2, "G", "R", s.Layout.LaneLength-1)
+	other := spawnTroop(s, 2, "G", "L", 3)
+	own := spawnTroop(s, 1, "G", "R", 3)
+
+	castSpell(t, s, 1, Cast{Spell: "A", Lane: "R"})
+	for _, g := range []*Troop{near, far} {
+		if g.HP != g.MaxHP-arrows.Damage {
+			t.Errorf("troop at %d has %d of %d HP, want %d damage", g.Position, g.HP, g.MaxHP, arrows.Damage)
+		}
+	}
+	if other.HP != other.MaxHP || own.HP != own.MaxHP {
+		t.Error("arrows hit a troop in another lane or of the caster")
+	}
+}
+
+func TestFreezeExpires(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	ticks := secondsToTicks(s.Catalog.Spell("Z").Duration)
+	knight := spawnTroop(s, 1, "K", "L", 0)
+	own := spawnTroop(s, 2, "K", "L", s.Layout.LaneLength-1)
+	castSpell(t, s, 2, Cast{Spell: "Z", Lane: "L"})
+	castTick := s.Tick
+
+	for i := 1; i < ticks; i++ {
+		Step(s, nil)
+		if knight.Position != 0 {
+			t.Fatalf("frozen Knight moved after %d ticks of %d", i, ticks)
+		}
+	}
+	if own.Position == s.Layout.LaneLength-1 {
+		t.Fatal("the caster's own troop was frozen")
+	}
+	Step(s, nil)
+	if len(s.Effects) != 0 {
+		t.Fatalf("freeze still active %d ticks after it was cast", s.Tick-castTick)
+	}
+	for i := 0; i < 30; i++ {
+		Step(s, nil)
+	}
+	if knight.Position == 0 {
+		t.Fatal("Knight did not move once the freeze wore off")
+	}
+}
+
+func TestRageExpires(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	rage := s.Catalog.Spell("E")
+	ticks := secondsToTicks(rage.Duration)
+	raged := spawnTroop(s, 1, "G", "L", 0)
+	enemy := spawnTroop(s, 2, "G", "L", s.Layout.LaneLength-1)
+	other := spawnTroop(s, 1, "G", "R", 0)
+	castSpell(t, s, 1, Cast{Spell: "E", Lane: "L"})
+
+	for i := 1; i < ticks; i++ {
+		Step(s, nil)
+		if got := rageBoost(s, raged); got != rage.Boost {
+			t.Fatalf("rage boost after %d ticks of %d is %d, want %d", i, ticks, got, rage.Boost)
+		}
+		if rageBoost(s, enemy) != 0 || rageBoost(s, other) != 0 {
+			t.Fatal("rage boosted an enemy or a troop in another lane")
+		}
+	}
+	Step(s, nil)
+	if got := rageBoost(s, raged); got != 0 {
+		t.Fatalf("rage boost is still %d after %d ticks", got, ticks)
+	}
+}
+
+func TestRageSpeedsUp(t *testing.T) {
+	s := newTestState(t, "long", 1)
+	raged := spawnTroop(s, 1, "K", "L", 0)
+	plain := spawnTroop(s, 1, "K", "R", 0)
+	castSpell(t, s, 1, Cast{Spell: "E", Lane: "L"})
+
+	for i := 0; i < secondsToTicks(s.Catalog.Spell("E").Duration); i++ {
+		Step(s, nil)
+	}
+	if raged.Position <= plain.Position {
+		t.Fatalf("raged Knight at %d is not ahead of the plain one at %d", raged.Position, plain.Position)
+	}
+}
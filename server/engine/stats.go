@@ -0,0 +1,40 @@
+package engine
+
+// PlayerStats sums up what one player did during a match
+type PlayerStats struct {
+	TroopsDeployed  int
+	SpellsCast      int
+	TroopsKilled    int // Enemy troops that died, by any cause
+	TroopsLost      int
+	TroopDamage     int // Damage dealt to enemy troops
+	TowerDamage     int // Damage dealt to enemy towers
+	TowersDestroyed int
+	CriticalHits    int
+	Healing         int
+}
+
+// Stats collects match statistics from the event stream
+type Stats [2]PlayerStats
+
+// Record adds one event to the statistics
+func (st *Stats) Record(ev Event) {
+	switch ev := ev.(type) {
+	case TroopDeployed:
+		st[ev.Player-1].TroopsDeployed++
+	case SpellCast:
+		st[ev.Player-1].SpellsCast++
+	case TroopAttacked:
+		st[2-ev.Player].TroopDamage += ev.Damage
+	case TroopDied:
+		st[ev.Player-1].TroopsLost++
+		st[2-ev.Player].TroopsKilled++
+	case TowerDamaged:
+		st[2-ev.Player].TowerDamage += ev.Damage
+	case TowerDestroyed:
+		st[2-ev.Player].TowersDestroyed++
+	case CriticalHit:
+		st[ev.Player-1].CriticalHits++
+	case Healed:
+		st[ev.Player-1].Healing += ev.Amount
+	}
+}
@@ -0,0 +1,86 @@
+package engine
+
+// towersShoot lets every active tower fire at an enemy troop within its
+// range. Towers act in a fixed order (player 1 first, then by lane) so a
+// match replays identically from the same seed.
+func towersShoot(s *State) {
+	for owner := 1; owner <= 2; owner++ {
+		wakeKing(s, owner)
+
+		for _, lane := range s.Layout.LaneIDs() {
+			tower := s.Towers[owner][lane]
+			if tower.HP <= 0 || !tower.Active {
+				continue
+			}
+			if tower.Cooldown > 0 {
+				tower.Cooldown--
+				continue
+			}
+
+			def := s.Catalog.Tower(tower.Kind)
+			target := towerTarget(s, owner, lane, def)
+			if target == nil {
+				continue
+			}
+			damage, crit := calculateDamage(s, tower.Atk, tower.Crit, target.Def)
+			if crit {
+				s.emit(CriticalHit{Player: owner, Lane: lane, Damage: damage})
+			}
+			damageTroop(s, target, damage, 0)
+			tower.Cooldown = secondsToTicks(def.AttackCooldown) - 1
+		}
+	}
+}
+
+// wakeKing activates a player's King tower once it has taken damage or one
+// of the player's guard towers has fallen
+func wakeKing(s *State, owner int) {
+	for _, tower := range s.Towers[owner] {
+		if tower.Kind != "King" || tower.Active {
+			continue
+		}
+		if tower.HP < tower.MaxHP {
+			tower.Active = true
+			continue
+		}
+		for _, other := range s.Towers[owner] {
+			if other.Kind == "Guard" && other.HP <= 0 {
+				tower.Active = true
+				break
+			}
+		}
+	}
+}
+
+// towerTarget picks the enemy troop a tower shoots at, or nil if none is in
+// range. "nearest" prefers the troop closest to the tower and "lowest_hp"
+// the weakest one; remaining ties go to the earliest deployed troop.
+func towerTarget(s *State, owner int, lane string, def *TowerDef) *Troop {
+	var target *Troop
+	for _, t := range s.Troops {
+		if !t.Alive || t.Player == owner || t.Lane != lane {
+			continue
+		}
+		dist := distance(t.Position, s.towerCell(owner))
+		if dist > def.Range {
+			continue
+		}
+		if target == nil {
+			target = t
+			continue
+		}
+
+		best := distance(target.Position, s.towerCell(owner))
+		switch def.Targeting {
+		case "lowest_hp":
+			if t.HP < target.HP || (t.HP == target.HP && dist < best) {
+				target = t
+			}
+		default:
+			if dist < best || (dist == best && t.HP < target.HP) {
+				target = t
+			}
+		}
+	}
+	return target
+}
@@ -0,0 +1,116 @@
+package engine
+
+import "testing"
+
+func TestGuardTowerTargetsNearest(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	guard := s.Catalog.Tower("Guard")
+
+	// Player 1's towers stand at coordinate -1, so cell 0 is next to them
+	spawnTroop(s, 2, "K", "L", 1)
+	near := spawnTroop(s, 2, "R", "L", 0)
+	spawnTroop(s, 2, "K", "L", 2) // Out of range
+	spawnTroop(s, 1, "K", "L", 0) // Own troop
+	spawnTroop(s, 2, "P", "R", 0) // Other lane
+	if got := towerTarget(s, 1, "L", guard); got != near {
+		t.Fatalf("guard tower targets %+v, want the nearest troop %+v", got, near)
+	}
+
+	// Player 2's towers stand on the other side of the lane
+	spawnTroop(s, 1, "P", "R", 3)
+	near = spawnTroop(s, 1, "K", "R", 4)
+	if got := towerTarget(s, 2, "R", guard); got != near {
+		t.Fatalf("player 2's guard tower targets %+v, want the nearest troop %+v", got, near)
+	}
+}
+
+func TestGuardTowerRange(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	guard := s.Catalog.Tower("Guard")
+
+	spawnTroop(s, 2, "K", "L", guard.Range)
+	if got := towerTarget(s, 1, "L", guard); got != nil {
+		t.Fatalf("guard tower targets %+v out of its range of %d", got, guard.Range)
+	}
+	inRange := spawnTroop(s, 2, "K", "L", guard.Range-1)
+	if got := towerTarget(s, 1, "L", guard); got != inRange {
+		t.Fatalf("guard tower targets %+v, want %+v at the edge of its range", got, inRange)
+	}
+}
+
+func TestKingTowerTargetsLowestHP(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	king := s.Catalog.Tower("King")
+
+	spawnTroop(s, 2, "K", "C", 0)
+	weak := spawnTroop(s, 2, "P", "C", 2)
+	if got := towerTarget(s, 1, "C", king); got != weak {
+		t.Fatalf("King tower targets %+v, want the weakest troop %+v", got, weak)
+	}
+
+	// Equal HP goes to the nearest troop
+	spawnTroop(s, 2, "P", "C", 1)
+	weak.HP = weak.MaxHP + 1
+	nearest := spawnTroop(s, 2, "P", "C", 0)
+	if got := towerTarget(s, 1, "C", king); got != nearest {
+		t.Fatalf("King tower targets %+v, want the nearest of the weakest %+v", got, nearest)
+	}
+}
+
+func TestKingWakesUp(t *testing.T) {
+	wake := map[string]func(s *State){
+		"damaged":    func(s *State) { s.Towers[1]["C"].HP-- },
+		"guard down": func(s *State) { s.Towers[1]["L"].HP = 0 },
+	}
+	for name, hit := range wake {
+		t.Run(name, func(t *testing.T) {
+			s := newTestState(t, "classic", 1)
+			king := s.Towers[1]["C"]
+			troop := spawnTroop(s, 2, "K", "C", 0)
+
+			towersShoot(s)
+			if king.Active || troop.HP != troop.MaxHP {
+				t.Fatal("sleeping King tower shot")
+			}
+
+			hit(s)
+			towersShoot(s)
+			if !king.Active {
+				t.Fatal("King tower did not wake up")
+			}
+			if troop.HP == troop.MaxHP {
+				t.Fatal("awake King tower did not shoot")
+			}
+			if s.Towers[2]["C"].Active {
+				t.Fatal("the other player's King tower woke up too")
+			}
+		})
+	}
+}
+
+func TestQueenHealsPastGuardTower(t *testing.T) {
+	s := newTestState(t, "classic", 1)
+	s.Towers[1]["L"].HP -= 500
+	hp := s.Towers[1]["L"].HP
+	queen := spawnTroop(s, 1, "Q", "R", s.DeployLimit(1, "R")-1)
+
+	healed := false
+	for queen.Alive && s.Tick < maxTestTicks {
+		_, events := Step(s, nil)
+		for _, ev := range events {
+			if h, ok := ev.(Healed); ok && h.Player == 1 && h.Lane == "L" {
+				healed = true
+			}
+		}
+	}
+	if s.Towers[2]["R"].HP <= 0 {
+		t.Fatal("the enemy guard tower fell; the test needs it standing")
+	}
+	if !healed {
+		t.Fatal("Queen was shot down by a standing guard tower before healing")
+	}
+	want := hp + s.Catalog.Troop("Q").Abilities[0].Amount
+	if got := s.Towers[1]["L"].HP; got != want {
+		t.Fatalf("healed tower has %d HP, want %d", got, want)
+	}
+}
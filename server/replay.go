@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
+)
+
+// runReplay re-simulates a replay file, printing its events, a map frame
+// every few seconds of game time and the final result. It returns the exit
+// code: 0 when the result matches the recording, 1 otherwise.
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	every := fs.Duration("every", 10*time.Second, "game time between printed map frames (0 prints only the last)")
+	quiet := fs.Bool("quiet", false, "print only the final result")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: server replay [-every 10s] [-quiet] <replay file>")
+		return 2
+	}
+
+	r, err := engine.LoadReplay(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot load replay:", err)
+		return 1
+	}
+
+	fmt.Printf("Replay of %s vs %s on %s, seed %d, catalog %.12s\n",
+		r.Players[0], r.Players[1], r.Layout, r.Seed, r.CatalogHash)
+	frameTicks := int(*every / engine.TickDuration)
+
+	s := r.Play(func(s *engine.State, events []engine.Event) {
+		if *quiet {
+			return
+		}
+		for _, ev := range events {
+			if _, ok := ev.(engine.CommandRejected); ok {
+				continue
+			}
+			if text := s.Describe(ev); text != "" {
+				fmt.Printf("[%s] %s\n", s.Elapsed(), text)
+			}
+		}
+		if frameTicks > 0 && s.Tick%frameTicks == 0 {
+			fmt.Printf("[%s]\n%s\n", s.Elapsed(), engine.RenderMap(s, 1))
+		}
+	})
+	if !*quiet {
+		fmt.Printf("[%s] Final map\n%s\n", s.Elapsed(), engine.RenderMap(s, 1))
+	}
+
+	winner, reason := r.Result(s)
+	fmt.Printf("Result: winner %d (%s), crowns %d-%d after %s\n",
+		winner, engine.ReasonText[reason], engine.Crowns(s, 1), engine.Crowns(s, 2), s.Elapsed())
+	if winner != r.Winner || reason != r.Reason || s.Tick != r.Ticks {
+		fmt.Printf("MISMATCH: recorded winner %d (%s) after %d ticks\n", r.Winner, engine.ReasonText[r.Reason], r.Ticks)
+		return 1
+	}
+	fmt.Println("Result matches the recording")
+	return 0
+}
+
+// runExport converts a replay file to a plain-text transcript or an asciicast
+// v2 recording. It returns the exit code.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text (transcript) or cast (asciicast v2)")
+	viewer := fs.Int("as", 1, "player whose side the cast shows the map from (1 or 2)")
+	out := fs.String("o", "", "output file (default standard output)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (*format != "text" && *format != "cast") || (*viewer != 1 && *viewer != 2) {
+		fmt.Fprintln(os.Stderr, "Usage: server export [-format text|cast] [-as 1|2] [-o file] <replay file>")
+		return 2
+	}
+
+	r, err := engine.LoadReplay(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot load replay:", err)
+		return 1
+	}
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot create output:", err)
+			return 1
+		}
+		defer w.Close()
+	}
+	if *format == "cast" {
+		err = engine.WriteAsciicast(w, r, *viewer)
+	} else {
+		err = engine.WriteTranscript(w, r)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Export failed:", err)
+		return 1
+	}
+	return 0
+}
+
+// sendReplay asks for a match number and sends that game's replay on a single
+// line starting with "REPLAY ", which the client's replay viewer waits for
+func sendReplay(client *Client, reader *bufio.Reader) {
+	client.conn.Write([]byte("Enter a match number:\n"))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	id, _ := strconv.Atoi(strings.TrimSpace(line))
+
+	matchLogMu.Lock()
+	results, err := loadMatchLog()
+	matchLogMu.Unlock()
+	if err != nil {
+		fmt.Println("Read match log error:", err)
+		client.conn.Write([]byte("Cannot read the match log. Disconnecting.\n"))
+		return
+	}
+	var path string
+	for _, result := range results {
+		if result.ID == id {
+			path = result.Replay
+		}
+	}
+	if path == "" {
+		client.conn.Write([]byte("No such match. Disconnecting.\n"))
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Read replay error:", err)
+		client.conn.Write([]byte("That match has no replay. Disconnecting.\n"))
+		return
+	}
+	// Replays are saved compact, but make sure the line cannot be split
+	data = bytes.ReplaceAll(data, []byte("\n"), nil)
+	client.conn.Write([]byte("REPLAY " + string(data) + "\n"))
+}
@@ -5,13 +5,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"math/rand"
+	"math/rand/v2"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/bots"
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
 )
 
 // Client represents a connected player or bot
@@ -20,46 +26,32 @@ type Client struct {
 	username  string
 	clientKey string
 	roomID    int
-	mana      int
 	inputCh   chan string
-	botLevel  int // 0 for human, 1-3 for bot difficulty
+	gone      chan struct{} // Closed once the connection is lost
+	botLevel  int           // 0 for human, else a key of botStrategies
 	gameMode  string
-	ready     bool // Used for replay readiness
-	exp       int  // Player's experience points
-	level     int  // Player's level
-}
-
-// Troop represents a unit deployed on the map
-type Troop struct {
-	player    int    // Player ID (1 or 2)
-	troopType string // Type of troop (P, B, R, K, I, Q)
-	lane      string // "L", "C", or "R"
-	position  int    // Current position on the lane (0-4)
-	age       int    // How many ticks the troop has been alive
-	alive     bool   // Is the troop still active?
-	hp        int    // Current HP
-	atk       int    // Attack power
-	def       int    // Defense
-}
-
-// Tower represents a defensive structure
-type Tower struct {
-	hp   int
-	atk  int
-	def  int
-	crit float64
+	ready     bool     // Used for replay readiness
+	exp       int      // Player's experience points
+	level     int      // Player's level
+	deck      []string // Player's chosen deck; nil uses the catalog's default deck
 }
 
 // Room represents a game session between two clients (or client and bot)
 type Room struct {
-	id       int
-	clients  [2]*Client                // Player 1 and Player 2 (or Bot)
-	troops   []*Troop                  // All active troops in the room
-	towerHP  map[int]map[string]int    // Tower HP for display
-	tower    map[int]map[string]*Tower // Tower stats
-	mu       sync.Mutex                // Mutex to protect room data
-	doneChan chan struct{}             // Channel to signal game over
-	started  time.Time                 // Game start time
+	id         int
+	clients    [2]*Client      // Player 1 and Player 2 (or Bot)
+	state      *engine.State   // Simulation state of the current game
+	seed       uint64          // Seed of the current game, logged for reproduction
+	rng        *rand.Rand      // Room-owned RNG for bot decisions, derived from seed
+	pending    []engine.Input  // Commands waiting for the next tick
+	crowns     [2]int          // Crowns each player has taken in the current game
+	stats      engine.Stats    // Statistics of the current game, built from its events
+	replay     *engine.Replay  // Recording of the current game, saved to a replay file when it ends
+	spectators []*Spectator    // Read-only viewers of the room
+	scaling    *engine.Scaling // Level scaling chosen by a private room's host; nil uses the server rules
+	mu         sync.Mutex      // Mutex to protect room data
+	doneChan   chan struct{}   // Channel to signal game over
+	started    time.Time       // Game start time
 }
 
 // PlayerData stores persistent player information for saving/loading
@@ -69,48 +61,73 @@ type PlayerData struct {
 	ClientKey string
 	Level     int
 	Exp       int
+	Deck      []string `json:",omitempty"` // Card ids the player brings to a match
+}
+
+// privateRoom is a private game waiting for the host's friend to join
+type privateRoom struct {
+	host    *Client
+	scaling engine.Scaling
+}
+
+// MatchResult is the record of one finished game
+type MatchResult struct {
+	ID      int       // Sequential match number
+	Room    int       // Room the game was played in
+	Players [2]string // Usernames of player 1 and player 2
+	Winner  int       // 1 or 2, or 0 for a draw
+	Reason  string    // Rule that decided the game
+	Crowns  [2]int    // Crowns taken by player 1 and player 2
+	Seed    uint64    // Seed of the game, for reproduction
+	Stats   engine.Stats
+	Replay  string // Replay file of the game
+	Ended   time.Time
 }
 
 var (
-	clients        = make(map[string]*Client) // All active client connections
-	rooms          = make(map[int]*Room)      // All active game rooms
-	waitingRoom    = make(chan *Client, 100)  // Channel for clients waiting for a PvP match
-	clientCount    = 0                        // Global counter for client keys
-	roomCount      = 0                        // Global counter for room IDs
-	globalMu       sync.Mutex                 // Mutex to protect global maps (clients, rooms)
-	playerDataFile = "players.json"           // File to store player data
-	onlineUsers    = make(map[string]bool)    // Map to track currently logged-in usernames
-	onlineUsersMu  sync.Mutex                 // Mutex to protect onlineUsers map
-
-	// Troop types and their base stats
-	troopTypes = map[string]struct {
-		hp   int
-		atk  int
-		def  int
-		mana int
-		spec string
-	}{
-		"P": {50, 150, 100, 3, ""},  // Pawn
-		"B": {100, 200, 150, 4, ""}, // Bishop
-		"R": {250, 200, 200, 5, ""}, // Rook
-		"K": {200, 300, 150, 5, ""}, // Knight
-		"I": {500, 400, 300, 6, ""}, // Prince
-		"Q": {1, 0, 0, 5, "heal"},   // Queen (special: heal tower)
-	}
-
-	// Tower base stats
-	towerBaseStats = map[string]struct {
-		hp   int
-		atk  int
-		def  int
-		crit float64
-	}{
-		"King":  {2000, 500, 300, 10},
-		"Guard": {1000, 300, 100, 5},
-	}
+	clients        = make(map[string]*Client)      // All active client connections
+	rooms          = make(map[int]*Room)           // All active game rooms (guarded by globalMu)
+	waitingRoom    = make(chan *Client, 100)       // Channel for clients waiting for a PvP match
+	privateRooms   = make(map[string]*privateRoom) // Private rooms waiting for a second player, by code (guarded by globalMu)
+	clientCount    = 0                             // Global counter for client keys
+	roomCount      = 0                             // Global counter for room IDs
+	globalMu       sync.Mutex                      // Mutex to protect global maps (clients, rooms)
+	playerDataFile = "players.json"                // File to store player data
+	matchLogFile   = "matches.json"                // File to store match results
+	frameInterval  = time.Second                   // How often players are sent the map and status line (guarded by globalMu)
+	spectatorDelay = 3 * time.Second               // How far behind the live game spectators are kept (guarded by globalMu)
+	replayDir      = "replays"                     // Directory for replay files of finished games
+	matchLogMu     sync.Mutex                      // Mutex to protect the match log and replay numbering
+	snapshotDir    = "snapshots"                   // Directory for snapshots of running games
+	snapshotEvery  = 30 * time.Second              // How often running games are saved; 0 only saves on shutdown (guarded by globalMu)
+	suspendedRooms = make(map[int]*suspendedRoom)  // Restored games waiting for their players, by room ID (guarded by globalMu)
+	catalogFile    = "catalog.json"                // File with troop and tower definitions
+	catalog        *engine.Catalog                 // Catalog used for new games (guarded by globalMu)
+	layoutName     = "classic"                     // Catalog layout used for new games (guarded by globalMu)
+	matchRules     = engine.DefaultRules()         // Rules used for new games (guarded by globalMu)
+	onlineUsers    = make(map[string]bool)         // Map to track currently logged-in usernames
+	onlineUsersMu  sync.Mutex                      // Mutex to protect onlineUsers map
 )
 
 func main() {
+	// "server replay <file>" re-simulates a recorded game and "server export
+	// <file>" converts one to a transcript or asciicast, instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+
+	var err error
+	catalog, err = engine.LoadCatalog(catalogFile)
+	if err != nil {
+		panic(fmt.Sprintf("cannot load %s: %v", catalogFile, err))
+	}
+
+	// Games running when the server last stopped wait for their players
+	loadSnapshots()
+
 	ln, err := net.Listen("tcp", ":8080")
 	if err != nil {
 		panic(err)
@@ -120,6 +137,15 @@ func main() {
 	fmt.Println("Server listening on port 8080...")
 
 	go matchPlayers() // Start the goroutine for matching players
+	go adminConsole() // Accept admin commands on standard input
+
+	// Save running games before stopping on Ctrl+C or a termination signal
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		shutdown()
+	}()
 
 	for {
 		conn, err := ln.Accept()
@@ -131,6 +157,234 @@ func main() {
 	}
 }
 
+// --- Admin Console ---
+
+// adminConsole reads admin commands from the server's standard input
+func adminConsole() {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "reload":
+			reloadCatalog()
+		case "layout":
+			if len(fields) != 2 {
+				fmt.Println("Usage: layout <name>")
+				continue
+			}
+			setLayout(fields[1])
+		case "overtime":
+			seconds := -1
+			if len(fields) == 2 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					seconds = n
+				}
+			}
+			if seconds < 0 {
+				fmt.Println("Usage: overtime <seconds> (0 disables overtime)")
+				continue
+			}
+			globalMu.Lock()
+			matchRules.Overtime = time.Duration(seconds) * time.Second
+			globalMu.Unlock()
+			fmt.Printf("New games will have %d seconds of overtime\n", seconds)
+		case "manacap":
+			limit := 0
+			if len(fields) == 2 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					limit = n
+				}
+			}
+			if limit < 1 {
+				fmt.Println("Usage: manacap <points>")
+				continue
+			}
+			globalMu.Lock()
+			matchRules.ManaCap = float64(limit)
+			globalMu.Unlock()
+			fmt.Printf("New games will cap mana at %d\n", limit)
+		case "triplemana":
+			if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+				fmt.Println("Usage: triplemana on|off")
+				continue
+			}
+			globalMu.Lock()
+			matchRules.TripleMana = fields[1] == "on"
+			globalMu.Unlock()
+			fmt.Printf("Triple mana event is %s for new games\n", fields[1])
+		case "crowns":
+			target := -1
+			if len(fields) == 2 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					target = n
+				}
+			}
+			if target < 0 {
+				fmt.Println("Usage: crowns <count> (0 disables the crown target)")
+				continue
+			}
+			globalMu.Lock()
+			matchRules.CrownsToWin = target
+			globalMu.Unlock()
+			fmt.Printf("New games are won at %d crowns\n", target)
+		case "frames":
+			ms := 0
+			if len(fields) == 2 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					ms = n
+				}
+			}
+			if ms < 100 {
+				fmt.Println("Usage: frames <milliseconds> (at least 100)")
+				continue
+			}
+			globalMu.Lock()
+			frameInterval = time.Duration(ms) * time.Millisecond
+			globalMu.Unlock()
+			fmt.Printf("New games send a frame every %dms\n", ms)
+		case "delay":
+			seconds := -1
+			if len(fields) == 2 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					seconds = n
+				}
+			}
+			if seconds < 0 {
+				fmt.Println("Usage: delay <seconds> (spectator delay)")
+				continue
+			}
+			globalMu.Lock()
+			spectatorDelay = time.Duration(seconds) * time.Second
+			globalMu.Unlock()
+			fmt.Printf("Spectators now watch %d seconds behind\n", seconds)
+		case "scaling":
+			scaling, err := engine.ParseScaling(strings.Join(fields[1:], " "))
+			if err != nil {
+				fmt.Println("Usage: scaling full|capped <level>|standard <level>")
+				continue
+			}
+			globalMu.Lock()
+			matchRules.Scaling = scaling
+			globalMu.Unlock()
+			fmt.Println("Ranked and bot games now use", scaling)
+		case "snapshots":
+			seconds := -1
+			if len(fields) == 2 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					seconds = n
+				}
+			}
+			if seconds < 0 {
+				fmt.Println("Usage: snapshots <seconds> (0 only saves games on shutdown)")
+				continue
+			}
+			globalMu.Lock()
+			snapshotEvery = time.Duration(seconds) * time.Second
+			globalMu.Unlock()
+			fmt.Printf("New games are saved every %d seconds\n", seconds)
+		case "snapshot":
+			fmt.Printf("Saved %d running games to %s\n", snapshotAll(""), snapshotDir)
+		case "resume":
+			if len(fields) != 2 {
+				listSuspended()
+				continue
+			}
+			sr, err := suspendSnapshot(fields[1])
+			if err != nil {
+				fmt.Println("Cannot resume:", err)
+				continue
+			}
+			fmt.Printf("Room %d (%s vs %s at %s) is waiting for its players\n",
+				sr.id, sr.snap.Players[0], sr.snap.Players[1], sr.state.Elapsed())
+		case "shutdown":
+			shutdown()
+		case "layouts":
+			for _, l := range currentCatalog().Layouts {
+				fmt.Printf("  %s: %d lanes of %d cells\n", l.Name, len(l.Lanes), l.LaneLength)
+			}
+		default:
+			fmt.Println("Admin commands: reload, layouts, layout <name>, overtime <seconds>, manacap <points>, triplemana on|off, crowns <count>, scaling <policy>, frames <milliseconds>, delay <seconds>, snapshots <seconds>, snapshot, resume [snapshot file], shutdown")
+		}
+	}
+}
+
+// reloadCatalog re-reads the catalog file. Games already running keep the
+// catalog they started with; only new games use the reloaded definitions.
+func reloadCatalog() {
+	c, err := engine.LoadCatalog(catalogFile)
+	if err != nil {
+		fmt.Println("Catalog reload failed, keeping the current one:", err)
+		return
+	}
+	globalMu.Lock()
+	catalog = c
+	globalMu.Unlock()
+	fmt.Printf("Catalog reloaded: %d troops, %d spells, %d towers, %d layouts\n",
+		len(c.Troops), len(c.Spells), len(c.Towers), len(c.Layouts))
+}
+
+// setLayout picks the arena layout for new games
+func setLayout(name string) {
+	if currentCatalog().Layout(name) == nil {
+		fmt.Println("Unknown layout:", name)
+		return
+	}
+	globalMu.Lock()
+	layoutName = name
+	globalMu.Unlock()
+	fmt.Println("New games will use layout", name)
+}
+
+// currentCatalog returns the catalog new games should use
+func currentCatalog() *engine.Catalog {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return catalog
+}
+
+// currentRules returns the rules new games should use
+func currentRules() engine.Rules {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return matchRules
+}
+
+// currentFrameInterval returns how often new games send frames to players
+func currentFrameInterval() time.Duration {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return frameInterval
+}
+
+// currentSpectatorDelay returns how far behind the live game spectators are kept
+func currentSpectatorDelay() time.Duration {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return spectatorDelay
+}
+
+// currentSnapshotInterval returns how often new games are saved to disk
+func currentSnapshotInterval() time.Duration {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return snapshotEvery
+}
+
+// currentLayout returns the layout new games should use. If a reloaded
+// catalog no longer has the selected layout, its default layout is used.
+func currentLayout(cat *engine.Catalog) *engine.Layout {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return cat.Layout(layoutName)
+}
+
 // --- Player Data Management ---
 
 // loadPlayerData loads all player data from the JSON file
@@ -167,72 +421,85 @@ func savePlayerData(player PlayerData) error {
 	return os.WriteFile(playerDataFile, data, 0644) // Write the updated JSON to file
 }
 
-// --- Game Logic Helpers ---
+// loadMatchLog reads every finished game from the match log. A missing log
+// means no games have been played yet. The caller must hold matchLogMu.
+func loadMatchLog() ([]MatchResult, error) {
+	var results []MatchResult
+	data, err := os.ReadFile(matchLogFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// recordMatch saves a finished game's replay and appends the game to the match log file
+func recordMatch(result MatchResult, recording *engine.Replay) error {
+	matchLogMu.Lock()
+	defer matchLogMu.Unlock()
+
+	results, err := loadMatchLog()
+	if err != nil {
+		return err
+	}
 
-// Fixed applyLevelScaling function
-func (c *Client) applyLevelScaling(base int) int {
-	return int(float64(base) * (1.0 + 0.1*float64(c.level)))
+	result.ID = len(results) + 1
+	if err := os.MkdirAll(replayDir, 0755); err != nil {
+		return err
+	}
+	result.Replay = filepath.Join(replayDir, fmt.Sprintf("match-%d.json", result.ID))
+	if err := recording.Save(result.Replay); err != nil {
+		return err
+	}
+	results = append(results, result)
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(matchLogFile, data, 0644)
 }
 
+// --- Game Logic Helpers ---
+
 // resetRoom resets the game state for a given room
 func resetRoom(room *Room) {
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
-	// Initialize towers
-	room.tower = make(map[int]map[string]*Tower)
-	room.towerHP = make(map[int]map[string]int)
-
-	// Player 1 towers
-	room.tower[1] = make(map[string]*Tower)
-	room.towerHP[1] = make(map[string]int)
-
-	// Player 2 towers
-	room.tower[2] = make(map[string]*Tower)
-	room.towerHP[2] = make(map[string]int)
+	// Every game gets its own seed so it can be reproduced from the log
+	room.seed = rand.Uint64()
+	room.rng = rand.New(rand.NewPCG(room.seed, ^room.seed))
 
-	// Set tower stats with level scaling
-	for playerID, client := range room.clients {
-		if client == nil {
-			continue
-		}
-		playerNum := playerID + 1
-
-		// King Tower (Center)
-		kingStats := towerBaseStats["King"]
-		room.tower[playerNum]["C"] = &Tower{
-			hp:   client.applyLevelScaling(kingStats.hp),
-			atk:  client.applyLevelScaling(kingStats.atk),
-			def:  client.applyLevelScaling(kingStats.def),
-			crit: kingStats.crit,
-		}
-		room.towerHP[playerNum]["C"] = room.tower[playerNum]["C"].hp
-
-		// Guard Towers (Left and Right)
-		guardStats := towerBaseStats["Guard"]
-		room.tower[playerNum]["L"] = &Tower{
-			hp:   client.applyLevelScaling(guardStats.hp),
-			atk:  client.applyLevelScaling(guardStats.atk),
-			def:  client.applyLevelScaling(guardStats.def),
-			crit: guardStats.crit,
-		}
-		room.towerHP[playerNum]["L"] = room.tower[playerNum]["L"].hp
-
-		room.tower[playerNum]["R"] = &Tower{
-			hp:   client.applyLevelScaling(guardStats.hp),
-			atk:  client.applyLevelScaling(guardStats.atk),
-			def:  client.applyLevelScaling(guardStats.def),
-			crit: guardStats.crit,
-		}
-		room.towerHP[playerNum]["R"] = room.tower[playerNum]["R"].hp
+	// Towers and troop stats are scaled by each player's level
+	cat := currentCatalog()
+	rules := currentRules()
+	if room.scaling != nil {
+		rules.Scaling = *room.scaling
 	}
-
-	room.troops = []*Troop{}
+	cfg := engine.Config{
+		Catalog: cat,
+		Layout:  currentLayout(cat),
+		Seed:    room.seed,
+		Levels:  [2]int{room.clients[0].level, room.clients[1].level},
+		Decks:   [2][]string{room.clients[0].deck, room.clients[1].deck},
+		Rules:   rules,
+	}
+	room.state = engine.NewState(cfg)
+	room.replay = engine.NewReplay(cfg, [2]string{room.clients[0].username, room.clients[1].username})
+	room.pending = nil
+	room.crowns = [2]int{}
+	room.stats = engine.Stats{}
 	room.started = time.Now()
 
+	fmt.Printf("Room %d game seeded with %d\n", room.id, room.seed)
+
 	for _, client := range room.clients {
 		if client != nil {
-			client.mana = 0
 			client.ready = false // Reset ready state for replay
 		}
 	}
@@ -264,33 +531,29 @@ func (c *Client) addExp(exp int) {
 		}
 	}
 
-	// Save updated player data (EXP and Level) to file
-	playerData := PlayerData{
-		Username:  c.username,
-		Password:  "", // Password not needed for update, loaded from file
-		ClientKey: c.clientKey,
-		Level:     c.level,
-		Exp:       c.exp,
-	}
-	if err := savePlayerData(playerData); err != nil {
-		fmt.Println("Error saving player data for", c.username, ":", err)
-	}
+	c.saveData()
 }
 
-// calculateDamage computes damage using CRIT chance and defense
-func calculateDamage(atk int, critChance float64, def int) int {
-	// Check for critical hit
-	isCrit := rand.Float64()*100 < critChance
-	baseDamage := atk
-	if isCrit {
-		baseDamage = int(float64(atk) * 1.2)
+// saveData saves the client's level, EXP and deck to file. The rest of the
+// stored record, such as the password, is kept as it is.
+func (c *Client) saveData() {
+	players, err := loadPlayerData()
+	if err != nil {
+		fmt.Println("Error loading player data for", c.username, ":", err)
+		return
 	}
-
-	damage := baseDamage - def
-	if damage < 0 {
-		return 0
+	playerData, exists := players[c.username]
+	if !exists {
+		fmt.Println("Error saving player data for", c.username, ": no stored record")
+		return
+	}
+	playerData.ClientKey = c.clientKey
+	playerData.Level = c.level
+	playerData.Exp = c.exp
+	playerData.Deck = c.deck
+	if err := savePlayerData(playerData); err != nil {
+		fmt.Println("Error saving player data for", c.username, ":", err)
 	}
-	return damage
 }
 
 // --- Connection and Authentication Handling ---
@@ -331,7 +594,9 @@ func handleConnection(conn net.Conn) {
 	}
 	authLine = strings.TrimSpace(authLine)
 	parts := strings.Split(authLine, ":")
-	if len(parts) != 2 {
+	// A third field of "replay" asks for a replay download instead of the menu
+	download := len(parts) == 3 && parts[2] == "replay"
+	if len(parts) != 2 && !download {
 		conn.Write([]byte("Invalid auth format. Use username:password\n"))
 		return
 	}
@@ -397,13 +662,14 @@ func handleConnection(conn net.Conn) {
 		conn:      conn,
 		username:  username,
 		clientKey: clientKey,
-		mana:      0,
 		inputCh:   make(chan string, 10),
+		gone:      make(chan struct{}),
 		botLevel:  0,
 		gameMode:  "",
 		ready:     false,
 		exp:       player.Exp,
 		level:     player.Level,
+		deck:      player.Deck,
 	}
 	clients[clientKey] = client
 	globalMu.Unlock()
@@ -416,20 +682,47 @@ func handleConnection(conn net.Conn) {
 	// --- End mark online ---
 
 	// Send authentication success message
-	conn.Write([]byte(fmt.Sprintf("%s_Authenticated. Level: %d, EXP: %d/%d\nChoose mode:\n1. Play vs Bot\n2. Play vs Player\n",
+	conn.Write([]byte(fmt.Sprintf("%s_Authenticated. Level: %d, EXP: %d/%d\n",
 		clientKey, client.level, client.exp, requiredExpForLevel(client.level))))
 
-	// Read game mode selection
-	modeLine, err := reader.ReadString('\n')
-	if err != nil {
-		fmt.Println("Read mode error:", err)
+	// The replay viewer only wants a file, so a suspended game stays suspended
+	if download {
+		sendReplay(client, reader)
+		return
+	}
+
+	// A game interrupted by a server restart is offered before the menu
+	resumed, ok := offerResume(client, reader)
+	if !ok {
 		return
 	}
-	mode := strings.TrimSpace(modeLine)
+	if resumed {
+		go listenClientInput(client)
+		go releaseWhenGone(client)
+		select {}
+	}
+
+	// Read game mode selection; editing the deck returns to the menu
+	var mode string
+	for {
+		conn.Write([]byte("Choose mode:\n1. Play vs Bot\n2. Play vs Player\n3. Edit deck\n4. Private room\n5. Watch a match\n6. Download a replay\n"))
+		modeLine, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Println("Read mode error:", err)
+			return
+		}
+		mode = strings.TrimSpace(modeLine)
+		if mode != "3" {
+			break
+		}
+		if !editDeck(client, reader) {
+			return
+		}
+	}
 
 	switch mode {
 	case "1": // Play vs Bot
-		conn.Write([]byte("Chọn độ khó:\n1. Dễ\n2. Vừa\n3. Khó\n"))
+		conn.Write([]byte("Chọn độ khó:\n1. Dễ\n2. Vừa\n3. Khó\n4. Phòng thủ\n5. Siêu khó\n"))
 		levelLine, err := reader.ReadString('\n')
 		if err != nil {
 			fmt.Println("Read level error:", err)
@@ -437,7 +730,7 @@ func handleConnection(conn net.Conn) {
 		}
 		levelLine = strings.TrimSpace(levelLine)
 		level, err := strconv.Atoi(levelLine)
-		if err != nil || level < 1 || level > 3 {
+		if err != nil || botStrategies[level] == "" {
 			conn.Write([]byte("Level không hợp lệ. Ngắt kết nối.\n"))
 			return
 		}
@@ -449,6 +742,21 @@ func handleConnection(conn net.Conn) {
 		client.gameMode = "pvp"
 		waitingRoom <- client
 
+	case "4": // Private room with a friend
+		client.gameMode = "private"
+		if !enterPrivateRoom(client, reader) {
+			return
+		}
+
+	case "5": // Watch a live match without playing
+		client.gameMode = "spectator"
+		watchMatch(client, reader)
+		return
+
+	case "6": // Send a finished game's replay to the client's viewer
+		sendReplay(client, reader)
+		return
+
 	default:
 		conn.Write([]byte("Invalid mode. Disconnecting.\n"))
 		return
@@ -456,10 +764,49 @@ func handleConnection(conn net.Conn) {
 
 	// Start goroutine to listen for client game input
 	go listenClientInput(client)
+	go releaseWhenGone(client)
 
 	select {}
 }
 
+// editDeck shows the client's deck and lets them pick a new one.
+// It returns false if the connection was lost.
+func editDeck(client *Client, reader *bufio.Reader) bool {
+	cat := currentCatalog()
+	deck := client.deck
+	if cat.ValidateDeck(deck) != nil {
+		deck = cat.DefaultDeck
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Your deck: %s\nCards:\n", strings.Join(deck, " ")))
+	for _, t := range cat.Troops {
+		sb.WriteString(fmt.Sprintf("    %s: %s (%d mana) - %s\n", t.ID, t.Name, t.Mana, t.Description))
+	}
+	for _, sp := range cat.Spells {
+		sb.WriteString(fmt.Sprintf("    %s: %s (%d mana) - %s\n", sp.ID, sp.Name, sp.Mana, sp.Description))
+	}
+	sb.WriteString(fmt.Sprintf("Enter %d card ids separated by spaces (empty line keeps your deck):\n", engine.DeckSize))
+	client.conn.Write([]byte(sb.String()))
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	cards := strings.Fields(strings.ToUpper(strings.ReplaceAll(line, ",", " ")))
+	if len(cards) == 0 {
+		return true
+	}
+	if err := cat.ValidateDeck(cards); err != nil {
+		client.conn.Write([]byte(fmt.Sprintf("Invalid deck: %v. Keeping your deck.\n", err)))
+		return true
+	}
+	client.deck = cards
+	client.saveData()
+	client.conn.Write([]byte("Deck saved!\n"))
+	return true
+}
+
 // listenClientInput reads commands from a client's connection
 func listenClientInput(client *Client) {
 	reader := bufio.NewReader(client.conn)
@@ -467,6 +814,7 @@ func listenClientInput(client *Client) {
 		line, err := reader.ReadString('\n')
 		if err != nil {
 			close(client.inputCh)
+			close(client.gone)
 			return
 		}
 		line = strings.TrimSpace(line)
@@ -474,6 +822,47 @@ func listenClientInput(client *Client) {
 	}
 }
 
+// releaseWhenGone waits for a client's connection to be lost. A client still
+// waiting for a game then, as a private room's host or for the other player
+// of a suspended game, is taken out of it and logged off. Clients in a game
+// are left to the game loop.
+func releaseWhenGone(client *Client) {
+	<-client.gone
+
+	waiting := false
+	globalMu.Lock()
+	for code, pr := range privateRooms {
+		if pr.host == client { // Nobody can join a disconnected host
+			delete(privateRooms, code)
+			waiting = true
+		}
+	}
+	for _, sr := range suspendedRooms {
+		if sr.waiting == client { // The other player will have to wait instead
+			sr.waiting = nil
+			waiting = true
+		}
+	}
+	globalMu.Unlock()
+
+	if waiting {
+		logOff(client)
+	}
+}
+
+// logOff forgets a client who leaves without a game to end, so they can log
+// back in
+func logOff(client *Client) {
+	globalMu.Lock()
+	delete(clients, client.clientKey)
+	globalMu.Unlock()
+
+	onlineUsersMu.Lock()
+	delete(onlineUsers, client.username)
+	onlineUsersMu.Unlock()
+	fmt.Printf("User %s is now offline.\n", client.username)
+}
+
 // --- Player Matching ---
 
 func matchPlayers() {
@@ -491,28 +880,84 @@ func matchPlayers() {
 			continue
 		}
 
+		startPvPGame(p1, p2, nil)
+	}
+}
+
+// startPvPGame creates a room for two players and starts its game loop.
+// A nil scaling uses the server's rules, as ranked matchmaking does.
+func startPvPGame(p1, p2 *Client, scaling *engine.Scaling) {
+	globalMu.Lock()
+	roomCount++
+	roomID := roomCount
+	globalMu.Unlock()
+
+	room := &Room{
+		id:       roomID,
+		clients:  [2]*Client{p1, p2},
+		doneChan: make(chan struct{}),
+		scaling:  scaling,
+	}
+	p1.roomID = roomID
+	p2.roomID = roomID
+	resetRoom(room)
+	// Spectators and snapshots may look at the room as soon as it is listed
+	globalMu.Lock()
+	rooms[roomID] = room
+	globalMu.Unlock()
+
+	fmt.Printf("Room %d created for %s (%s) vs %s (%s)\n", roomID, p1.username, p1.clientKey, p2.username, p2.clientKey)
+
+	go gameLoop(room)
+}
+
+// enterPrivateRoom lets a client host a private room with a level scaling
+// policy of their choice, or join a friend's room by its code.
+// It returns false if the client should be disconnected.
+func enterPrivateRoom(client *Client, reader *bufio.Reader) bool {
+	client.conn.Write([]byte("Enter a room code to join, or NEW to host a private room:\n"))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	code := strings.ToUpper(strings.TrimSpace(line))
+
+	if code != "NEW" {
 		globalMu.Lock()
-		roomCount++
-		roomID := roomCount
+		pr, ok := privateRooms[code]
+		delete(privateRooms, code)
 		globalMu.Unlock()
-
-		room := &Room{
-			id:       roomID,
-			clients:  [2]*Client{p1, p2},
-			troops:   []*Troop{},
-			tower:    make(map[int]map[string]*Tower),
-			towerHP:  make(map[int]map[string]int),
-			doneChan: make(chan struct{}),
+		if !ok {
+			client.conn.Write([]byte(fmt.Sprintf("No private room with code %s. Disconnecting.\n", code)))
+			return false
 		}
-		p1.roomID = roomID
-		p2.roomID = roomID
-		rooms[roomID] = room
-		resetRoom(room)
+		startPvPGame(pr.host, client, &pr.scaling)
+		return true
+	}
 
-		fmt.Printf("Room %d created for %s (%s) vs %s (%s)\n", roomID, p1.username, p1.clientKey, p2.username, p2.clientKey)
+	client.conn.Write([]byte("Level scaling: full, capped <level> or standard <level> (empty line for full):\n"))
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	scaling := engine.Scaling{Mode: engine.ScalingFull}
+	if strings.TrimSpace(line) != "" {
+		scaling, err = engine.ParseScaling(line)
+		if err != nil {
+			client.conn.Write([]byte(fmt.Sprintf("Invalid scaling: %v. Disconnecting.\n", err)))
+			return false
+		}
+	}
 
-		go gameLoop(room)
+	globalMu.Lock()
+	for code == "NEW" || privateRooms[code] != nil {
+		code = fmt.Sprintf("%04d", rand.IntN(10000))
 	}
+	privateRooms[code] = &privateRoom{host: client, scaling: scaling}
+	globalMu.Unlock()
+
+	client.conn.Write([]byte(fmt.Sprintf("Private room %s created with %s. Share the code and wait for your friend...\n", code, scaling)))
+	return true
 }
 
 // startBotGame initializes and starts a game with a bot
@@ -522,10 +967,33 @@ func startBotGame(p1 *Client, level int) {
 	roomID := roomCount
 	globalMu.Unlock()
 
-	bot := &Client{
+	bot := newBot(level)
+	room := &Room{
+		id:       roomID,
+		clients:  [2]*Client{p1, bot},
+		doneChan: make(chan struct{}),
+	}
+	p1.roomID = roomID
+	bot.roomID = roomID
+	resetRoom(room)
+	globalMu.Lock()
+	rooms[roomID] = room
+	globalMu.Unlock()
+
+	fmt.Printf("Room %d created for %s (%s) vs %s\n", roomID, p1.username, p1.clientKey, bot.username)
+
+	go runBot(room, bot)
+	go gameLoop(room)
+}
+
+// botStrategies names the strategy behind each bot difficulty in the menu
+var botStrategies = map[int]string{1: "easy", 2: "medium", 3: "hard", 4: "defensive", 5: "insane"}
+
+// newBot creates the client a bot of the given difficulty plays through
+func newBot(level int) *Client {
+	return &Client{
 		username:  fmt.Sprintf("BotLv%d", level),
 		clientKey: "Bot",
-		mana:      0,
 		inputCh:   make(chan string, 10),
 		botLevel:  level,
 		gameMode:  "bot",
@@ -533,60 +1001,48 @@ func startBotGame(p1 *Client, level int) {
 		exp:       0,
 		level:     1,
 	}
+}
 
-	room := &Room{
-		id:       roomID,
-		clients:  [2]*Client{p1, bot},
-		troops:   []*Troop{},
-		tower:    make(map[int]map[string]*Tower),
-		towerHP:  make(map[int]map[string]int),
-		doneChan: make(chan struct{}),
-	}
-	p1.roomID = roomID
-	bot.roomID = roomID
-	rooms[roomID] = room
-	resetRoom(room)
+// runBot plays for the bot as player 2 until the room's game loop ends.
+// Every game gets a fresh strategy, which decides from a copy of the match
+// so it never holds up the game loop.
+func runBot(room *Room, bot *Client) {
+	name := botStrategies[bot.botLevel]
+	var strategy bots.Strategy
+	var seed uint64
+	for {
+		room.mu.Lock()
+		if strategy == nil || seed != room.seed {
+			var err error
+			if strategy, err = bots.New(name, room.rng); err != nil {
+				room.mu.Unlock()
+				fmt.Println("Bot error in room", room.id, ":", err)
+				return
+			}
+			seed = room.seed
+		}
+		room.mu.Unlock()
 
-	fmt.Printf("Room %d created for %s (%s) vs %s\n", roomID, p1.username, p1.clientKey, bot.username)
+		select {
+		case <-room.doneChan:
+			return
+		case <-time.After(strategy.Delay()):
+		}
 
-	go func() {
-		for {
+		room.mu.Lock()
+		view := bots.NewView(room.state, 2)
+		room.mu.Unlock()
+		if view.Over() {
+			continue
+		}
+		for _, command := range strategy.Decide(view) {
 			select {
+			case bot.inputCh <- command:
 			case <-room.doneChan:
 				return
-			default:
-				var delay time.Duration
-				switch level {
-				case 1:
-					delay = 7 * time.Second
-				case 2:
-					delay = 4 * time.Second
-				case 3:
-					delay = 2 * time.Second
-				}
-				time.Sleep(delay)
-
-				room.mu.Lock()
-				if bot.mana >= 5 {
-					var command string
-					switch level {
-					case 1:
-						command = "P-L"
-					case 2:
-						command = "B-C"
-					case 3:
-						troops := []string{"P", "B", "R", "K", "I"}
-						lanes := []string{"L", "R"}
-						command = fmt.Sprintf("%s-%s", troops[rand.Intn(len(troops))], lanes[rand.Intn(len(lanes))])
-					}
-					bot.inputCh <- command
-				}
-				room.mu.Unlock()
 			}
 		}
-	}()
-
-	go gameLoop(room)
+	}
 }
 
 // --- Game Loop and Core Mechanics ---
@@ -598,6 +1054,12 @@ func gameLoop(room *Room) {
 		delete(rooms, room.id)
 		globalMu.Unlock()
 
+		room.mu.Lock()
+		for len(room.spectators) > 0 {
+			room.removeSpectator(room.spectators[0])
+		}
+		room.mu.Unlock()
+
 		for _, c := range room.clients {
 			if c != nil && c.clientKey != "Bot" {
 				onlineUsersMu.Lock()
@@ -615,17 +1077,19 @@ func gameLoop(room *Room) {
 	p1 := room.clients[0]
 	p2 := room.clients[1]
 
-	startMsg := `Game started! Commands:
-    P-L: Deploy Pawn to Left lane
-    B-C: Deploy Bishop to Center lane
-    R-R: Deploy Rook to Right lane
-    K-L: Deploy Knight to Left lane
-    I-R: Deploy Prince to Right lane
-    Q-C: Deploy Queen to Center lane
-
+	room.mu.Lock()
+	heading := "Game started!"
+	if room.state.Tick > 0 { // Restored from a snapshot
+		heading = "Game resumed!"
+	}
+	startMsg := heading + " Commands:\n" + commandHelp(room.state) + `
     Strategy:
     - Destroy both Left and Right Towers before attacking the King Tower
-    - Queen heals friendly towers when she reaches them`
+    - Queen heals your weakest tower when she comes within range of the enemy towers
+    - Add a cell to deploy further forward, e.g. K-L-3: up to the middle of a lane, or anywhere once its enemy tower falls
+    - Only the cards in your hand can be played; a played card goes to the back of your deck
+    Level scaling: ` + room.state.Rules.Scaling.String()
+	room.mu.Unlock()
 
 	if p1.conn != nil {
 		p1.conn.Write([]byte(fmt.Sprintf("%s\n", startMsg)))
@@ -634,8 +1098,18 @@ func gameLoop(room *Room) {
 		p2.conn.Write([]byte(fmt.Sprintf("%s\n", startMsg)))
 	}
 
-	ticker := time.NewTicker(2 * time.Second)
+	// The simulation runs at a fixed rate; players get frames at their own pace
+	ticker := time.NewTicker(engine.TickDuration)
 	defer ticker.Stop()
+	frames := time.NewTicker(currentFrameInterval())
+	defer frames.Stop()
+	// Running games are saved regularly so they survive a crash
+	var snapshots <-chan time.Time
+	if interval := currentSnapshotInterval(); interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		snapshots = t.C
+	}
 
 	gameOver := false
 	winner := 0
@@ -647,7 +1121,7 @@ loop:
 		case cmd, ok := <-p1.inputCh:
 			if !ok {
 				winner = 2
-				reason = "disconnect"
+				reason = engine.ReasonDisconnect
 				gameOver = true
 				break loop
 			}
@@ -658,7 +1132,7 @@ loop:
 		case cmd, ok := <-p2.inputCh:
 			if !ok {
 				winner = 1
-				reason = "disconnect"
+				reason = engine.ReasonDisconnect
 				gameOver = true
 				break loop
 			}
@@ -668,96 +1142,87 @@ loop:
 
 		case <-ticker.C:
 			room.mu.Lock()
-			if p1.mana < 100 {
-				p1.mana += 1
-			}
-			if p2.mana < 100 {
-				p2.mana += 1
-			}
-
-			updateTroops(room)
-			applyCombat(room)
+			room.replay.Record(room.state, room.pending)
+			_, events := engine.Step(room.state, room.pending)
+			room.pending = nil
+			room.crowns = [2]int{engine.Crowns(room.state, 1), engine.Crowns(room.state, 2)}
+			dispatchEvents(room, events)
 
-			// Check king tower for game end
-			if room.tower[1]["C"].hp <= 0 {
-				gameOver = true
-				winner = 2
-				reason = "king_tower"
-				room.mu.Unlock()
-				break loop
-			}
-			if room.tower[2]["C"].hp <= 0 {
+			if room.state.Over() {
 				gameOver = true
-				winner = 1
-				reason = "king_tower"
+				winner = room.state.Winner
+				reason = room.state.Reason
 				room.mu.Unlock()
 				break loop
 			}
+			room.mu.Unlock()
 
-			// Check game timer (3 minutes)
-			if time.Since(room.started) >= 3*time.Minute {
-				gameOver = true
-				reason = "time_up"
-				room.mu.Unlock()
-				break loop
-			}
+		case <-frames.C:
+			room.mu.Lock()
+			sendFrame(room)
+			room.mu.Unlock()
 
-			mapStr := renderMap(room)
-			if p1.conn != nil {
-				p1.conn.Write([]byte(fmt.Sprintf("%s_Mana: %d, Level: %d, EXP: %d/%d\n%s\n",
-					p1.clientKey, p1.mana, p1.level, p1.exp, requiredExpForLevel(p1.level), mapStr)))
-			}
-			if p2.conn != nil && p2 != p1 {
-				p2.conn.Write([]byte(fmt.Sprintf("%s_Mana: %d, Level: %d, EXP: %d/%d\n%s\n",
-					p2.clientKey, p2.mana, p2.level, p2.exp, requiredExpForLevel(p2.level), mapStr)))
+		case <-snapshots:
+			room.mu.Lock()
+			if err := saveSnapshot(room); err != nil {
+				fmt.Println("Error saving snapshot of room", room.id, ":", err)
 			}
 			room.mu.Unlock()
 		}
 	}
 
 	if gameOver {
-		// Handle time_up win condition
-		if reason == "time_up" {
-			p1TowersDestroyed := 0
-			p2TowersDestroyed := 0
-
-			// Count destroyed towers (both guard and king)
-			for _, tower := range room.tower[1] {
-				if tower.hp <= 0 {
-					p2TowersDestroyed++ // Player 2 destroyed Player 1's tower
-				}
-			}
-			for _, tower := range room.tower[2] {
-				if tower.hp <= 0 {
-					p1TowersDestroyed++ // Player 1 destroyed Player 2's tower
-				}
-			}
+		room.mu.Lock()
+		room.replay.Finish(room.state, winner, reason)
+		deleteSnapshot(room)
+		room.mu.Unlock()
+		err := recordMatch(MatchResult{
+			Room:    room.id,
+			Players: [2]string{p1.username, p2.username},
+			Winner:  winner,
+			Reason:  reason,
+			Crowns:  room.crowns,
+			Seed:    room.seed,
+			Stats:   room.stats,
+			Ended:   time.Now(),
+		}, room.replay)
+		if err != nil {
+			fmt.Println("Error recording match for room", room.id, ":", err)
+		}
 
-			if p1TowersDestroyed > p2TowersDestroyed {
-				winner = 1
-			} else if p2TowersDestroyed > p1TowersDestroyed {
-				winner = 2
-			} else {
-				winner = 0 // Draw
-			}
+		room.mu.Lock()
+		if winner == 0 {
+			room.toSpectators(fmt.Sprintf("\nGAME OVER! It's a draw, %d-%d crowns! (%s)\n", room.crowns[0], room.crowns[1], engine.ReasonText[reason]))
+		} else {
+			room.toSpectators(fmt.Sprintf("\nGAME OVER! Player %d (%s) wins, %d-%d crowns! (%s)\n",
+				winner, room.clients[winner-1].username, room.crowns[0], room.crowns[1], engine.ReasonText[reason]))
 		}
+		room.mu.Unlock()
 
-		// Send game over message
-		for _, c := range room.clients {
+		// Send game over message; every crown is worth EXP, win or lose
+		for i, c := range room.clients {
 			if c.conn == nil {
 				continue
 			}
 
+			score := fmt.Sprintf("%d-%d crowns", room.crowns[i], room.crowns[1-i])
+			exp := 10 * room.crowns[i]
 			switch {
 			case winner == 0:
-				c.conn.Write([]byte("\nGAME OVER! It's a draw!\n"))
+				c.conn.Write([]byte(fmt.Sprintf("\nGAME OVER! It's a draw, %s! (%s)\n", score, engine.ReasonText[reason])))
 			case c == room.clients[winner-1]:
-				c.conn.Write([]byte(fmt.Sprintf("\nGAME OVER! You win! (%s)\n", reason)))
-				c.addExp(30)
-				c.conn.Write([]byte(fmt.Sprintf("You gained 30 EXP! Total EXP: %d/%d\n",
-					c.exp, requiredExpForLevel(c.level))))
+				c.conn.Write([]byte(fmt.Sprintf("\nGAME OVER! You win %s! (%s)\n", score, engine.ReasonText[reason])))
+				exp += 30
 			default:
-				c.conn.Write([]byte(fmt.Sprintf("\nGAME OVER! Player %d wins! (%s)\n", winner, reason)))
+				c.conn.Write([]byte(fmt.Sprintf("\nGAME OVER! Player %d wins, %s! (%s)\n", winner, score, engine.ReasonText[reason])))
+			}
+			st := room.stats[i]
+			c.conn.Write([]byte(fmt.Sprintf("Your stats: %d troops deployed, %d spells cast, %d kills, %d troops lost, %d damage to troops, %d damage to towers\n",
+				st.TroopsDeployed, st.SpellsCast, st.TroopsKilled, st.TroopsLost, st.TroopDamage, st.TowerDamage)))
+			if exp > 0 {
+				c.addExp(exp)
+				c.conn.Write([]byte(fmt.Sprintf("You gained %d EXP! Total EXP: %d/%d\n",
+					exp, c.exp, requiredExpForLevel(c.level))))
 			}
 		}
 
@@ -849,6 +1314,9 @@ loop:
 					client.conn.Write([]byte(startMsg))
 				}
 			}
+			room.mu.Lock()
+			room.toSpectators(startMsg)
+			room.mu.Unlock()
 			goto loop
 		} else {
 			fmt.Printf("Not all players in Room %d want to replay. Ending session.\n", room.id)
@@ -861,6 +1329,25 @@ loop:
 	}
 }
 
+// sendFrame sends each player their status line, hand and view of the map,
+// and queues the spectators' frame. The caller must hold room.mu.
+func sendFrame(room *Room) {
+	watching := ""
+	if n := len(room.spectators); n > 0 {
+		watching = fmt.Sprintf(", Spectators: %d", n)
+	}
+	for i, c := range room.clients {
+		if c.conn == nil {
+			continue
+		}
+		player := i + 1
+		c.conn.Write([]byte(fmt.Sprintf("%s_Mana: %s, Crowns: %d-%d%s, Level: %d, EXP: %d/%d\n%s\n%s\n",
+			c.clientKey, manaStatus(room.state, player), room.crowns[i], room.crowns[1-i], watching, c.level, c.exp, requiredExpForLevel(c.level),
+			handStatus(room.state, player), engine.RenderMap(room.state, player))))
+	}
+	room.toSpectators(spectatorFrame(room))
+}
+
 // handleReplayResponse processes Y/N input for replay prompt
 func handleReplayResponse(room *Room, client *Client, cmd string) bool {
 	cmd = strings.ToUpper(strings.TrimSpace(cmd))
@@ -886,281 +1373,100 @@ func handleReplayResponse(room *Room, client *Client, cmd string) bool {
 
 // --- Game Action Processors ---
 
-func processCommand(room *Room, player int, cmd string) {
-	cmd = strings.ToUpper(strings.TrimSpace(cmd))
-	var troopType string
-	var lane string
+// commandHelp lists one example command per troop and spell in the catalog
+func commandHelp(s *engine.State) string {
+	cat := s.Catalog
+	lanes := s.Layout.Lanes
 
-	parts := strings.Split(cmd, "-")
-	if len(parts) != 2 {
-		return
+	var sb strings.Builder
+	for i, t := range cat.Troops {
+		lane := lanes[i%len(lanes)]
+		sb.WriteString(fmt.Sprintf("    %s-%s: Deploy %s to %s lane (%d mana) - %s\n",
+			t.ID, lane.ID, t.Name, lane.Name, t.Mana, t.Description))
 	}
 
-	troopType = parts[0]
-	lane = parts[1]
-
-	// Validate troop type
-	if _, valid := troopTypes[troopType]; !valid {
-		if room.clients[player-1].conn != nil {
-			room.clients[player-1].conn.Write([]byte("Invalid troop type! Use P, B, R, K, I, or Q.\n"))
+	// Spells aimed at a cell take a third part counted from your own towers
+	for i, sp := range cat.Spells {
+		lane := lanes[i%len(lanes)]
+		target := fmt.Sprintf("%s-%s", sp.ID, lane.ID)
+		if sp.TargetsCell() {
+			target += fmt.Sprintf("-%d", s.Layout.LaneLength-1)
 		}
-		return
+		sb.WriteString(fmt.Sprintf("    %s: Cast %s on %s lane (%d mana) - %s\n",
+			target, sp.Name, lane.Name, sp.Mana, sp.Description))
 	}
+	return sb.String()
+}
 
-	// Validate lane
-	if lane != "L" && lane != "C" && lane != "R" {
-		if room.clients[player-1].conn != nil {
-			room.clients[player-1].conn.Write([]byte("Invalid lane! Use L, C, or R.\n"))
-		}
+// processCommand parses a player's text command and queues it for the next tick
+func processCommand(room *Room, player int, cmd string) {
+	command, err := engine.ParseCommand(room.state, player, cmd)
+	if err == engine.ErrMalformed {
 		return
 	}
-
-	var c *Client
-	if player == 1 {
-		c = room.clients[0]
-	} else {
-		c = room.clients[1]
-	}
-
-	// Check mana cost
-	requiredMana := troopTypes[troopType].mana
-	if c.mana < requiredMana {
-		if c.conn != nil {
-			c.conn.Write([]byte(fmt.Sprintf("Not enough mana (need %d)!\n", requiredMana)))
+	if err != nil {
+		if room.clients[player-1].conn != nil {
+			room.clients[player-1].conn.Write([]byte(err.Error() + "\n"))
 		}
 		return
 	}
-	c.mana -= requiredMana
-
-	// Create troop with level-scaled stats
-	base := troopTypes[troopType]
-	newTroop := &Troop{
-		player:    player,
-		troopType: troopType,
-		lane:      lane,
-		position:  4,
-		age:       0,
-		alive:     true,
-		hp:        c.applyLevelScaling(base.hp),
-		atk:       c.applyLevelScaling(base.atk),
-		def:       c.applyLevelScaling(base.def),
-	}
-	room.troops = append(room.troops, newTroop)
-
-	if c.conn != nil {
-		c.conn.Write([]byte(fmt.Sprintf("Deployed %s to %s lane\n", getTroopName(troopType), lane)))
-	}
+	room.pending = append(room.pending, engine.Input{Player: player, Command: command})
 }
 
-// getTroopName returns the full name of a troop
-func getTroopName(troopType string) string {
-	names := map[string]string{
-		"P": "Pawn",
-		"B": "Bishop",
-		"R": "Rook",
-		"K": "Knight",
-		"I": "Prince",
-		"Q": "Queen",
-	}
-	return names[troopType]
-}
-
-func findEnemyTroopAt(room *Room, troop *Troop, pos int) *Troop {
-	for _, t := range room.troops {
-		if t.alive && t.player != troop.player && t.lane == troop.lane && t.position == pos {
-			return t
-		}
-	}
-	return nil
-}
-
-func updateTroops(room *Room) {
-	var newTroops []*Troop
-
-	for _, t := range room.troops {
-		if !t.alive {
+// dispatchEvents feeds simulation events to the room's statistics, the
+// server's match log and the players
+func dispatchEvents(room *Room, events []engine.Event) {
+	for _, ev := range events {
+		room.stats.Record(ev)
+		text := room.state.Describe(ev)
+		if text == "" {
 			continue
 		}
-		t.age++
 
-		if t.age%2 != 0 {
-			newTroops = append(newTroops, t)
-			continue
-		}
-
-		nextPos := t.position - 1
-		if nextPos < 0 {
-			newTroops = append(newTroops, t)
-			continue
-		}
-
-		// Calculate enemy position (mirrored)
-		enemyPos := 4 - t.position
-		enemy := findEnemyTroopAt(room, t, enemyPos)
-		if enemy != nil {
-			// Troop vs troop combat
-			troopDamage := calculateDamage(t.atk, 0, enemy.def)
-			enemy.hp -= troopDamage
-
-			enemyDamage := calculateDamage(enemy.atk, 0, t.def)
-			t.hp -= enemyDamage
-
-			if t.hp <= 0 {
-				t.alive = false
-			}
-			if enemy.hp <= 0 {
-				enemy.alive = false
+		// A rejected command only concerns the player who sent it
+		if ev, ok := ev.(engine.CommandRejected); ok {
+			if c := room.clients[ev.Player-1]; c.conn != nil {
+				c.conn.Write([]byte(text + "\n"))
 			}
-
-			newTroops = append(newTroops, t)
-			continue
-		}
-
-		t.position = nextPos
-		newTroops = append(newTroops, t)
-	}
-
-	var aliveTroops []*Troop
-	for _, t := range newTroops {
-		if t.alive {
-			aliveTroops = append(aliveTroops, t)
-		}
-	}
-	room.troops = aliveTroops
-}
-
-// applyCombat handles troop-tower interactions and special abilities
-func applyCombat(room *Room) {
-	for _, t := range room.troops {
-		if !t.alive || t.position != 0 {
 			continue
 		}
 
-		enemyPlayer := 3 - t.player
-		tower := room.tower[enemyPlayer][t.lane]
-
-		// Handle Queen special ability (heals friendly tower)
-		if t.troopType == "Q" {
-			// Find lowest HP friendly tower
-			friendlyPlayer := t.player
-			lowestHP := 1000000
-			var healLane string
-
-			for lane, tower := range room.tower[friendlyPlayer] {
-				if tower.hp > 0 && tower.hp < lowestHP {
-					lowestHP = tower.hp
-					healLane = lane
-				}
-			}
-
-			// Heal the tower
-			if healLane != "" {
-				tower := room.tower[friendlyPlayer][healLane]
-				healAmount := 300
-				tower.hp += healAmount
-				room.towerHP[friendlyPlayer][healLane] = tower.hp
-
-				// Notify players
-				for _, c := range room.clients {
-					if c.conn != nil {
-						c.conn.Write([]byte(
-							fmt.Sprintf("Queen healed Player %d's %s tower by %d HP!\n",
-								friendlyPlayer, healLane, healAmount)))
-					}
-				}
-			}
-
-			// Remove Queen after healing
-			t.alive = false
-			continue
-		}
-
-		// Normal troop attacks tower
-		troopDamage := calculateDamage(t.atk, 0, tower.def)
-		tower.hp -= troopDamage
-		room.towerHP[enemyPlayer][t.lane] = tower.hp
-
-		// Tower attacks troop
-		towerDamage := calculateDamage(tower.atk, tower.crit, t.def)
-		t.hp -= towerDamage
-
-		if t.hp <= 0 {
-			t.alive = false
-		}
-
-		// Enhanced tower destruction logic
-		if tower.hp <= 0 {
-			// Tower destroyed - check if it's a guard tower
-			if t.lane == "L" || t.lane == "R" {
-				// Check if both guard towers are destroyed
-				if room.tower[enemyPlayer]["L"].hp <= 0 && room.tower[enemyPlayer]["R"].hp <= 0 {
-					// Both side towers destroyed - move to center
-					t.lane = "C"
-					t.position = 4
-				} else if room.tower[enemyPlayer]["L"].hp <= 0 {
-					// Only left tower destroyed - move to right tower
-					t.lane = "R"
-					t.position = 4
-				} else if room.tower[enemyPlayer]["R"].hp <= 0 {
-					// Only right tower destroyed - move to left tower
-					t.lane = "L"
-					t.position = 4
-				}
+		fmt.Printf("Room %d [%s]: %s\n", room.id, room.state.Elapsed(), text)
+		for _, c := range room.clients {
+			if c.conn != nil {
+				c.conn.Write([]byte(text + "\n"))
 			}
 		}
+		room.toSpectators(text + "\n")
 	}
 }
 
-// --- Map Rendering ---
+// --- Status Rendering ---
 
-func renderMap(room *Room) string {
-	lanes := map[string][]string{
-		"L": {" ", " ", " ", " ", " "},
-		"C": {" ", " ", " ", " ", " "},
-		"R": {" ", " ", " ", " ", " "},
+// handStatus lists the cards a player can play and the card that comes next
+func handStatus(s *engine.State, player int) string {
+	p := s.Player(player)
+	cards := make([]string, len(p.Hand))
+	for i, card := range p.Hand {
+		cards[i] = fmt.Sprintf("[%s %s %d]", card, s.Catalog.CardName(card), s.Catalog.CardMana(card))
 	}
+	return fmt.Sprintf("Hand: %s  Next: %s", strings.Join(cards, " "), s.Catalog.CardName(p.Next()))
+}
 
-	for _, t := range room.troops {
-		if !t.alive || t.position < 0 || t.position > 4 {
-			continue
-		}
-
-		symbol := fmt.Sprintf("%s%d", t.troopType, t.player)
-
-		if t.player == 2 {
-			lanes[t.lane][t.position] = symbol
-		} else {
-			lanes[t.lane][4-t.position] = symbol
-		}
+// manaStatus renders a player's mana as a bar with the time until the next point
+func manaStatus(s *engine.State, player int) string {
+	mana := s.Player(player).Mana
+	limit := int(s.Rules.ManaCap)
+	full := min(int(mana), limit)
+	bar := strings.Repeat("#", full) + strings.Repeat("-", limit-full)
+	status := fmt.Sprintf("[%s] %.1f/%d", bar, mana, limit)
+	if next := s.NextManaIn(player); next > 0 {
+		status += fmt.Sprintf(", +1 in %.1fs", next.Seconds())
+	} else {
+		status += ", full"
 	}
-
-	formatHP := func(hp int) string {
-		if hp < 5 {
-			return "X"
-		}
-		return fmt.Sprintf("%d", hp)
+	if mult := s.ManaMultiplier(); mult != 1 {
+		status += fmt.Sprintf(" (x%g mana)", mult)
 	}
-
-	p1HP := room.towerHP[1]
-	p2HP := room.towerHP[2]
-
-	lineSep := "                          --- --- --- --- ---"
-
-	mapStr := fmt.Sprintf(`+---------------------- TEXT CLASH ROYALE MAP ----------------------+
-
-[P1 TowerL - %s]  ===>  | %s | %s | %s | %s | %s |  <===  [P2 TowerL - %s]
-%s
-[P1 KingTower - %s] => | %s | %s | %s | %s | %s | <= [P2 KingTower - %s]
-%s
-[P1 TowerR - %s]  ===>  | %s | %s | %s | %s | %s |  <===  [P2 TowerR - %s]
-
-+------------------------------------------------------------------+`,
-		formatHP(p1HP["L"]), lanes["L"][0], lanes["L"][1], lanes["L"][2], lanes["L"][3], lanes["L"][4], formatHP(p2HP["L"]),
-		lineSep,
-		formatHP(p1HP["C"]), lanes["C"][0], lanes["C"][1], lanes["C"][2], lanes["C"][3], lanes["C"][4], formatHP(p2HP["C"]),
-		lineSep,
-		formatHP(p1HP["R"]), lanes["R"][0], lanes["R"][1], lanes["R"][2], lanes["R"][3], lanes["R"][4], formatHP(p2HP["R"]),
-	)
-
-	return mapStr
+	return status
 }
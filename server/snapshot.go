@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
+)
+
+// roomSnapshot is a running game saved to disk so it survives a server
+// restart. Commands still waiting for the next tick are not saved.
+type roomSnapshot struct {
+	Room     int
+	Players  [2]string       // Usernames of player 1 and player 2
+	BotLevel int             // Difficulty of the bot playing as player 2, 0 if both are people
+	Scaling  *engine.Scaling // Private room scaling, nil for the server rules
+	Seed     uint64
+	Crowns   [2]int
+	Stats    engine.Stats
+	Replay   json.RawMessage  // Recording of the game so far
+	Game     *engine.Snapshot // Simulation state, including the RNG
+	Saved    time.Time
+}
+
+// suspendedRoom is a restored game waiting for its players to log in again
+type suspendedRoom struct {
+	id      int
+	path    string // File the snapshot was loaded from
+	snap    roomSnapshot
+	state   *engine.State
+	replay  *engine.Replay
+	waiting *Client // Player who has already chosen to resume, waiting for the other
+}
+
+// snapshotPath is the file a room's snapshot is kept in
+func snapshotPath(id int) string {
+	return filepath.Join(snapshotDir, fmt.Sprintf("room-%d.json", id))
+}
+
+// saveSnapshot writes the room's current game to its snapshot file.
+// The caller must hold room.mu.
+func saveSnapshot(room *Room) error {
+	game, err := room.state.Snapshot()
+	if err != nil {
+		return err
+	}
+	recording, err := json.Marshal(room.replay)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(roomSnapshot{
+		Room:     room.id,
+		Players:  [2]string{room.clients[0].username, room.clients[1].username},
+		BotLevel: room.clients[1].botLevel,
+		Scaling:  room.scaling,
+		Seed:     room.seed,
+		Crowns:   room.crowns,
+		Stats:    room.stats,
+		Replay:   recording,
+		Game:     game,
+		Saved:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		return err
+	}
+	// Write beside the old snapshot and swap, so a crash mid-write keeps it
+	path := snapshotPath(room.id)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// deleteSnapshot removes the snapshot of a room whose game has ended
+func deleteSnapshot(room *Room) {
+	if err := os.Remove(snapshotPath(room.id)); err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error removing snapshot of room", room.id, ":", err)
+	}
+}
+
+// snapshotAll saves every game still being played and tells its players
+// notice, if one is given. It returns how many games were saved.
+func snapshotAll(notice string) int {
+	globalMu.Lock()
+	live := make([]*Room, 0, len(rooms))
+	for _, room := range rooms {
+		live = append(live, room)
+	}
+	globalMu.Unlock()
+
+	saved := 0
+	for _, room := range live {
+		room.mu.Lock()
+		if room.replay.Finished() { // Waiting for play-again answers
+			room.mu.Unlock()
+			continue
+		}
+		if err := saveSnapshot(room); err != nil {
+			fmt.Println("Error saving snapshot of room", room.id, ":", err)
+		} else {
+			saved++
+			for _, c := range room.clients {
+				if c.conn != nil && notice != "" {
+					c.conn.Write([]byte(notice))
+				}
+			}
+		}
+		room.mu.Unlock()
+	}
+	return saved
+}
+
+// shutdown saves every running game and stops the server
+func shutdown() {
+	n := snapshotAll("\nThe server is shutting down. Your game has been saved; log in again to resume it.\n")
+	fmt.Printf("Saved %d running games to %s. Server stopped.\n", n, snapshotDir)
+	os.Exit(0)
+}
+
+// loadSnapshots suspends the games that were running when the server last
+// stopped, until their players log in again
+func loadSnapshots() {
+	paths, err := filepath.Glob(filepath.Join(snapshotDir, "room-*.json"))
+	if err != nil {
+		fmt.Println("Error listing snapshots:", err)
+		return
+	}
+	for _, path := range paths {
+		if sr, err := suspendSnapshot(path); err != nil {
+			fmt.Printf("Cannot restore %s: %v\n", path, err)
+		} else {
+			fmt.Printf("Room %d (%s vs %s at %s) is waiting for its players\n",
+				sr.id, sr.snap.Players[0], sr.snap.Players[1], sr.state.Elapsed())
+		}
+	}
+}
+
+// suspendSnapshot restores a snapshot file as a suspended game. The game
+// keeps its room number unless that room is in use.
+func suspendSnapshot(path string) (*suspendedRoom, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap roomSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	if snap.Game == nil {
+		return nil, fmt.Errorf("snapshot has no game")
+	}
+	state, err := snap.Game.Restore()
+	if err != nil {
+		return nil, err
+	}
+	recording, err := engine.ParseReplay(snap.Replay)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot replay: %v", err)
+	}
+
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	humans := snap.Players[:]
+	if snap.BotLevel > 0 {
+		humans = humans[:1]
+	}
+	for _, name := range humans {
+		if other := findSuspended(name); other != nil {
+			return nil, fmt.Errorf("%s already has a suspended game in room %d", name, other.id)
+		}
+	}
+	id := snap.Room
+	if rooms[id] != nil || suspendedRooms[id] != nil || id <= 0 {
+		roomCount++
+		id = roomCount
+	}
+	roomCount = max(roomCount, id)
+	sr := &suspendedRoom{id: id, path: path, snap: snap, state: state, replay: recording}
+	suspendedRooms[id] = sr
+	return sr, nil
+}
+
+// findSuspended returns the suspended game a player takes part in, if any.
+// The caller must hold globalMu.
+func findSuspended(username string) *suspendedRoom {
+	for _, sr := range suspendedRooms {
+		if sr.snap.Players[0] == username || (sr.snap.BotLevel == 0 && sr.snap.Players[1] == username) {
+			return sr
+		}
+	}
+	return nil
+}
+
+// offerResume asks a player with a suspended game whether to resume it.
+// resumed reports whether the player is now in, or waiting for, that game;
+// ok is false if the connection was lost.
+func offerResume(client *Client, reader *bufio.Reader) (resumed, ok bool) {
+	globalMu.Lock()
+	sr := findSuspended(client.username)
+	globalMu.Unlock()
+	if sr == nil {
+		return false, true
+	}
+
+	seat := 0
+	if sr.snap.Players[0] != client.username {
+		seat = 1
+	}
+	opponent := sr.snap.Players[1-seat]
+	client.conn.Write([]byte(fmt.Sprintf("Your game against %s was interrupted at %s with crowns %d-%d. Resume it? (Y/N)\n",
+		opponent, sr.state.Elapsed(), sr.snap.Crowns[seat], sr.snap.Crowns[1-seat])))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return false, false
+	}
+
+	globalMu.Lock()
+	if suspendedRooms[sr.id] != sr { // The other player discarded it meanwhile
+		globalMu.Unlock()
+		client.conn.Write([]byte("That game is no longer available.\n"))
+		return false, true
+	}
+	if strings.ToUpper(strings.TrimSpace(line)) != "Y" {
+		delete(suspendedRooms, sr.id)
+		globalMu.Unlock()
+		discardSuspended(sr)
+		client.conn.Write([]byte("Interrupted game discarded.\n"))
+		return false, true
+	}
+	if sr.snap.BotLevel == 0 && sr.waiting == nil {
+		sr.waiting = client
+		globalMu.Unlock()
+		client.conn.Write([]byte(fmt.Sprintf("Waiting for %s to log in and resume the game...\n", opponent)))
+		return true, true
+	}
+	delete(suspendedRooms, sr.id)
+	globalMu.Unlock()
+
+	restoreRoom(sr, client)
+	return true, true
+}
+
+// discardSuspended drops a suspended game nobody will resume. A player
+// already waiting for it is told and disconnected.
+func discardSuspended(sr *suspendedRoom) {
+	if sr.path == snapshotPath(sr.id) {
+		os.Remove(sr.path)
+	}
+	if sr.waiting != nil && sr.waiting.conn != nil {
+		sr.waiting.conn.Write([]byte("Your opponent chose not to resume the game. Disconnecting.\n"))
+		sr.waiting.conn.Close()
+		logOff(sr.waiting)
+	}
+	fmt.Printf("Suspended game in room %d discarded\n", sr.id)
+}
+
+// restoreRoom turns a suspended game back into a running room, with client
+// and the player waiting for it (or a new bot) in their old seats
+func restoreRoom(sr *suspendedRoom, client *Client) {
+	room := &Room{
+		id:       sr.id,
+		state:    sr.state,
+		replay:   sr.replay,
+		seed:     sr.snap.Seed,
+		rng:      rand.New(rand.NewPCG(sr.snap.Seed, ^sr.snap.Seed)),
+		crowns:   sr.snap.Crowns,
+		stats:    sr.snap.Stats,
+		scaling:  sr.snap.Scaling,
+		doneChan: make(chan struct{}),
+		started:  time.Now(),
+	}
+	var bot *Client
+	switch {
+	case sr.snap.BotLevel > 0:
+		bot = newBot(sr.snap.BotLevel)
+		room.clients = [2]*Client{client, bot}
+	case sr.snap.Players[0] == client.username:
+		room.clients = [2]*Client{client, sr.waiting}
+	default:
+		room.clients = [2]*Client{sr.waiting, client}
+	}
+	for _, c := range room.clients {
+		c.roomID = room.id
+		c.gameMode = "resumed"
+	}
+
+	room.mu.Lock()
+	if err := saveSnapshot(room); err != nil {
+		fmt.Println("Error saving snapshot of room", room.id, ":", err)
+	}
+	room.mu.Unlock()
+	globalMu.Lock()
+	rooms[room.id] = room
+	globalMu.Unlock()
+
+	fmt.Printf("Room %d resumed at %s for %s vs %s\n", room.id, room.state.Elapsed(),
+		room.clients[0].username, room.clients[1].username)
+	for i, c := range room.clients {
+		if c.conn != nil {
+			c.conn.Write([]byte(fmt.Sprintf("Resuming your game at %s with crowns %d-%d!\n",
+				room.state.Elapsed(), room.crowns[i], room.crowns[1-i])))
+		}
+	}
+
+	if bot != nil {
+		go runBot(room, bot)
+	}
+	go gameLoop(room)
+}
+
+// listSuspended prints the games waiting for their players to the admin console
+func listSuspended() {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if len(suspendedRooms) == 0 {
+		fmt.Println("No suspended games")
+		return
+	}
+	ids := make([]int, 0, len(suspendedRooms))
+	for id := range suspendedRooms {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		sr := suspendedRooms[id]
+		waiting := ""
+		if sr.waiting != nil {
+			waiting = fmt.Sprintf(", %s is waiting", sr.waiting.username)
+		}
+		fmt.Printf("  Room %d: %s vs %s at %s, saved %s from %s%s\n", id, sr.snap.Players[0], sr.snap.Players[1],
+			sr.state.Elapsed(), sr.snap.Saved.Format(time.DateTime), sr.path, waiting)
+	}
+}
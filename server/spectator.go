@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinh21176/Netcentric_TCR/server/engine"
+)
+
+// Spectator is a read-only viewer of a room. Everything sent to a spectator
+// goes through its feed and is written spectatorDelay later, so watching a
+// match cannot be used to help one of the players.
+type Spectator struct {
+	client *Client
+	feed   chan spectatorMsg
+}
+
+// spectatorMsg is a piece of text and the time it may be shown
+type spectatorMsg struct {
+	at   time.Time
+	text string
+}
+
+// run writes the feed to the spectator's connection as each message comes due,
+// and closes the connection once the feed is closed
+func (sp *Spectator) run() {
+	for msg := range sp.feed {
+		time.Sleep(time.Until(msg.at))
+		sp.client.conn.Write([]byte(msg.text))
+	}
+	sp.client.conn.Close()
+}
+
+// toSpectators queues text for every spectator of the room. A spectator who
+// falls too far behind misses messages rather than stalling the game.
+// The caller must hold room.mu.
+func (room *Room) toSpectators(text string) {
+	at := time.Now().Add(currentSpectatorDelay())
+	for _, sp := range room.spectators {
+		select {
+		case sp.feed <- spectatorMsg{at: at, text: text}:
+		default:
+		}
+	}
+}
+
+// removeSpectator stops feeding a spectator. It is safe to call more than
+// once. The caller must hold room.mu.
+func (room *Room) removeSpectator(sp *Spectator) {
+	for i, other := range room.spectators {
+		if other == sp {
+			room.spectators = append(room.spectators[:i], room.spectators[i+1:]...)
+			close(sp.feed)
+			return
+		}
+	}
+}
+
+// spectatorFrame is the status line and map shown to spectators.
+// The caller must hold room.mu.
+func spectatorFrame(room *Room) string {
+	return fmt.Sprintf("Spectating room %d: %s (P1) vs %s (P2), Crowns: %d-%d, Time: %s\n%s\n",
+		room.id, room.clients[0].username, room.clients[1].username,
+		room.crowns[0], room.crowns[1], room.state.Elapsed().Truncate(time.Second), engine.RenderMap(room.state, 1))
+}
+
+// watchMatch lists the live rooms, lets the client pick one and feeds it that
+// room's frames and events until the match or the connection ends. Anything
+// the spectator types is refused.
+func watchMatch(client *Client, reader *bufio.Reader) {
+	globalMu.Lock()
+	live := make([]*Room, 0, len(rooms))
+	for _, room := range rooms {
+		live = append(live, room)
+	}
+	globalMu.Unlock()
+	sort.Slice(live, func(i, j int) bool { return live[i].id < live[j].id })
+
+	if len(live) == 0 {
+		client.conn.Write([]byte("No matches are being played right now. Disconnecting.\n"))
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("Live matches:\n")
+	for _, room := range live {
+		room.mu.Lock()
+		sb.WriteString(fmt.Sprintf("    %d: %s vs %s (%s played, %d watching)\n",
+			room.id, room.clients[0].username, room.clients[1].username,
+			room.state.Elapsed().Truncate(time.Second), len(room.spectators)))
+		room.mu.Unlock()
+	}
+	sb.WriteString("Enter a room number to watch:\n")
+	client.conn.Write([]byte(sb.String()))
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	id, _ := strconv.Atoi(strings.TrimSpace(line))
+	globalMu.Lock()
+	room := rooms[id]
+	globalMu.Unlock()
+	if room == nil {
+		client.conn.Write([]byte("No such match. Disconnecting.\n"))
+		return
+	}
+
+	sp := &Spectator{client: client, feed: make(chan spectatorMsg, 256)}
+	room.mu.Lock()
+	room.spectators = append(room.spectators, sp)
+	room.mu.Unlock()
+	client.conn.Write([]byte(fmt.Sprintf("Watching room %d with a %s delay. You cannot send commands.\n",
+		id, currentSpectatorDelay())))
+	go sp.run()
+
+	for {
+		if _, err := reader.ReadString('\n'); err != nil {
+			break
+		}
+		client.conn.Write([]byte("Spectators cannot send commands.\n"))
+	}
+	room.mu.Lock()
+	room.removeSpectator(sp)
+	room.mu.Unlock()
+}